@@ -3,7 +3,9 @@ package mojo
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"time"
 )
 
 // Application is the main type for an application to use. Applications
@@ -11,13 +13,50 @@ import (
 // global application configuration and tools that can be used by those
 // handlers.
 type Application struct {
+	// Mode is the mode the application is running in, from the
+	// MOJO_MODE environment variable. Defaults to "development". The
+	// mode decides the default log level, the detail shown on the
+	// exception and not found pages, whether templates are cached, and
+	// the Cache-Control header for static files. See: AddModeSetup
+	Mode     string
 	Home     File
 	Routes   Routes
 	Static   *Static
 	Log      Log
 	hooks    map[Hook][]HookHandler
+	modes    map[string][]func(*Application)
 	Commands map[string]Command
 	Renderer Renderer
+
+	// Types maps formats to MIME types for the Content-Type header and
+	// content negotiation. See: Context.RespondTo
+	Types *Types
+
+	// Secrets are used to sign session cookies, newest first. Cookies are
+	// signed with the first secret and verified with any of them, so new
+	// secrets can be added to the front without logging everyone out.
+	// See: Context.Session
+	Secrets []string
+
+	// Sessions configures the session cookie. See: Context.Session
+	Sessions *Sessions
+
+	// Defaults are the default stash values for every Context. Stash
+	// values are merged in this order, with later values taking
+	// precedence:
+	//
+	//   1. Application Defaults
+	//   2. Route Defaults, from the top-level route to the endpoint
+	//   3. Values captured by route placeholders
+	//   4. Values given to Context.Render
+	//
+	// The "path" stash value is always set from the request.
+	Defaults Stash
+
+	// PathNormalization controls how request paths are normalized before
+	// they are given to the Static and Routes dispatchers in the "path"
+	// stash value.
+	PathNormalization PathNormalization
 }
 
 // Hook is label for an application event that can have HookHandlers
@@ -34,7 +73,9 @@ const (
 	// Application.Dispatch function is called for the current Context
 	BeforeDispatch Hook = "BeforeDispatch"
 	// AfterDispatch is a hook that is called after the
-	// request has been dispatched to the Static or Routes dispatcher.
+	// request has been dispatched to the Static or Routes dispatcher,
+	// and after any delayed response has been rendered (see:
+	// Context.RenderLater).
 	// Good for rewriting outgoing responses and other post-processing
 	// tasks.
 	AfterDispatch Hook = "AfterDispatch"
@@ -44,6 +85,13 @@ const (
 	AfterStatic Hook = "AfterDispatch"
 )
 
+// modeLogLevels are the default log levels for application modes. Other
+// modes use "info". Set MOJO_LOG_LEVEL to override the default.
+var modeLogLevels = map[string]string{
+	"development": "debug",
+	"test":        "error",
+}
+
 // NewApplication builds a basic Mojo application with the default set
 // of commands and (TODO) plugins.
 func NewApplication() *Application {
@@ -56,15 +104,33 @@ func NewApplication() *Application {
 		}
 	}
 
+	mode := os.Getenv("MOJO_MODE")
+	if mode == "" {
+		mode = "development"
+	}
+
 	app := &Application{
+		Mode:     mode,
 		Commands: map[string]Command{},
 		Renderer: &GoRenderer{},
 		Log:      NewLog(),
-		Static:   &Static{},
+		Static:   &Static{MaxAge: time.Hour},
+		Types:    NewTypes(),
+		Sessions: NewSessions(),
+
+		PathNormalization: DefaultPathNormalization,
+	}
+	if os.Getenv("MOJO_LOG_LEVEL") == "" {
+		level, ok := modeLogLevels[mode]
+		if !ok {
+			level = "info"
+		}
+		app.Log.Level(level)
 	}
 	app.Commands["help"] = &HelpCommand{App: app}
 	app.Commands["version"] = &VersionCommand{App: app}
 	app.Commands["daemon"] = &DaemonCommand{App: app}
+	app.Commands["routes"] = &RoutesCommand{App: app}
 	app.Static.AddPath(NewFile(home).Child("public"))
 
 	return app
@@ -83,11 +149,21 @@ func (app *Application) BuildContext(req *Request, res *Response) *Context {
 		res = NewResponse()
 	}
 	c := &Context{Req: req, Res: res, App: app, Stash: map[string]interface{}{}}
+	c.Stash.Merge(app.Defaults)
 
-	// XXX: Add defaults from application
 	// Set default stash values from request
 	// XXX: Add URL to Request
 	c.Stash["path"] = c.Req.URL.Path
+	if c.Req.URL.Path != "" {
+		escaped := c.Req.URL.EscapedPath()
+		normal := normalizePath(escaped, app.PathNormalization)
+		if normal != escaped {
+			c.canonicalPath = normal
+			if path, err := url.PathUnescape(normal); err == nil {
+				c.Stash["path"] = path
+			}
+		}
+	}
 
 	return c
 }
@@ -119,35 +195,79 @@ func (app *Application) emit(hook Hook, c *Context) {
 // Handler handles the request for the given Context. This includes
 // dispatching the BeforeDispatch and AfterDispatch hooks, trying the
 // Static dispatch and Routes dispatch, and writing the response to the
-// user (if it hasn't been already)
+// user (if it hasn't been already). If the handler called
+// Context.RenderLater, Handler waits for the response to be rendered.
+//
+// If a handler panics, the panic is logged and the "exception" template
+// is rendered with a 500 Internal Server Error status. The built-in
+// exception template shows the error, the source code around it, and
+// the request in "development" mode, and a plain error page in other
+// modes. See: Context.Exception
 func (app *Application) Handler(c *Context) {
-	// XXX: Capture panics?
+	app.protect(c, func() { app.dispatch(c) })
+
+	// Wait for delayed responses before running the AfterDispatch hooks.
+	// See: Context.RenderLater
+	if err := c.wait(); err != nil && !c.timeout(err) {
+		return
+	}
+	app.protect(c, func() { app.emit(AfterDispatch, c) })
+
+	// Write the response, unless it was already streamed
+	if c.streaming {
+		return
+	}
+	if !c.rendered {
+		c.Render("")
+	}
+	c.finalize()
+}
+
+// protect calls the given function, rendering the exception page if it
+// panics. See: Context.Exception
+func (app *Application) protect(c *Context, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.Exception(newException(r, 2))
+		}
+	}()
+	fn()
+}
+
+// dispatch emits the BeforeDispatch hook and tries the Static dispatch
+// and Routes dispatch for the given Context. It does not emit the
+// AfterDispatch hook or write the response, since a delayed response may
+// still be rendering.
+func (app *Application) dispatch(c *Context) {
 	app.emit(BeforeDispatch, c)
 	if app.Static.Dispatch(c) {
 		app.emit(AfterStatic, c)
 	} else {
 		app.Routes.Dispatch(c)
 	}
-	app.emit(AfterDispatch, c)
+}
 
-	// Write the response
-	if !c.rendered {
-		c.Render("")
-	}
-	if c.Res.Code == 0 {
-		c.Res.Code = 200
+// AddModeSetup registers a function to set up the application when it
+// starts in the given mode, like adding template paths or changing
+// settings for "production". Setup functions are called by Start.
+func (app *Application) AddModeSetup(mode string, setup func(*Application)) {
+	if app.modes == nil {
+		app.modes = map[string][]func(*Application){}
 	}
-	if c.Res.Writer != nil {
-		// XXX: Copy response headers
-		c.Res.Writer.WriteHeader(c.Res.Code)
-		// XXX: Build Body from whatever parts we have
-		c.Res.Content.Serve(c.Res.Writer)
+	app.modes[mode] = append(app.modes[mode], setup)
+}
+
+// setupMode calls the setup functions for the application's mode
+func (app *Application) setupMode() {
+	for _, setup := range app.modes[app.Mode] {
+		setup(app)
 	}
 }
 
 // Start invokes the Application's commands using the arguments given on
 // the command-line.
 func (app *Application) Start() {
+	app.setupMode()
 	name := os.Args[1]
 	cmd, ok := app.Commands[name]
 	if !ok {
@@ -2,11 +2,14 @@ package mojo_test
 
 import (
 	"fmt"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 
 	"github.com/preaction/mojo.go"
 	"github.com/preaction/mojo.go/testmojo"
@@ -67,7 +70,7 @@ func TestApplicationServeHTTP(t *testing.T) {
 	app.ServeHTTP(res, req)
 }
 
-func ExampleApplicationHelloWorld() {
+func ExampleApplication_helloWorld() {
 	app := mojo.NewApplication()
 	app.Renderer.AddTemplate("greet", "Hello, <% .Stash.name %>!\n")
 
@@ -93,7 +96,7 @@ func ExampleApplicationHelloWorld() {
 	// Hello, Gophers!
 }
 
-func ExampleApplicationJSON() {
+func ExampleApplication_json() {
 	type Employee struct {
 		Name  string `json:"name"`
 		Email string `json:"email"`
@@ -150,3 +153,262 @@ func ExampleApplicationJSON() {
 	// application/json
 	// 404
 }
+
+func TestApplicationHead(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/").To(func(c *mojo.Context) { c.Res.Text("Hello, World!") })
+
+	c := app.BuildContext(mojo.NewRequest("HEAD", "/"), mojo.NewResponse(httptest.NewRecorder()))
+	app.Handler(c)
+	res, body := testmojo.ReadHTTPResponse(t, c)
+	if res.Code != 200 {
+		t.Errorf("Status %d != 200", res.Code)
+	}
+	if len(body) != 0 {
+		t.Errorf("HEAD response has a body: %s", body)
+	}
+}
+
+type testCommand struct{ ran bool }
+
+func (cmd *testCommand) Description() string     { return "Test command" }
+func (cmd *testCommand) Usage() string           { return "" }
+func (cmd *testCommand) Run(args []string) error { cmd.ran = true; return nil }
+
+func TestApplicationMode(t *testing.T) {
+	t.Setenv("MOJO_MODE", "")
+	t.Setenv("MOJO_LOG_LEVEL", "")
+	app := mojo.NewApplication()
+	if app.Mode != "development" {
+		t.Errorf("Default mode incorrect. Got: %s", app.Mode)
+	}
+	log := &strings.Builder{}
+	app.Log.Handle = log
+	app.Log.Debug("Debug")
+	if log.String() == "" {
+		t.Errorf("Development mode does not log debug messages")
+	}
+
+	t.Setenv("MOJO_MODE", "production")
+	app = mojo.NewApplication()
+	if app.Mode != "production" {
+		t.Errorf("Mode not read from MOJO_MODE. Got: %s", app.Mode)
+	}
+	log = &strings.Builder{}
+	app.Log.Handle = log
+	app.Log.Debug("Debug")
+	app.Log.Info("Info")
+	if strings.Contains(log.String(), "Debug") || !strings.Contains(log.String(), "Info") {
+		t.Errorf("Production mode log level incorrect: %s", log.String())
+	}
+
+	t.Setenv("MOJO_LOG_LEVEL", "debug")
+	app = mojo.NewApplication()
+	log = &strings.Builder{}
+	app.Log.Handle = log
+	app.Log.Debug("Debug")
+	if log.String() == "" {
+		t.Errorf("MOJO_LOG_LEVEL does not override mode log level")
+	}
+}
+
+func TestApplicationAddModeSetup(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Mode = "production"
+	setup := []string{}
+	app.AddModeSetup("production", func(app *mojo.Application) { setup = append(setup, "production") })
+	app.AddModeSetup("development", func(app *mojo.Application) { setup = append(setup, "development") })
+	app.AddModeSetup("production", func(app *mojo.Application) { setup = append(setup, "production 2") })
+
+	cmd := &testCommand{}
+	app.Commands["test"] = cmd
+	defer func(args []string) { os.Args = args }(os.Args)
+	os.Args = []string{"app", "test"}
+	app.Start()
+
+	if !cmd.ran {
+		t.Errorf("Command did not run")
+	}
+	if strings.Join(setup, ",") != "production,production 2" {
+		t.Errorf("Mode setup incorrect. Got: %v", setup)
+	}
+}
+
+func TestApplicationModeCaching(t *testing.T) {
+	templates := fstest.MapFS{"hello.html.tmpl": &fstest.MapFile{Data: []byte("Hello")}}
+	app := mojo.NewApplication()
+	app.Renderer.AddFS(templates)
+	app.Static.Paths = []fs.FS{fstest.MapFS{"hello.txt": &fstest.MapFile{Data: []byte("Hello")}}}
+	app.Routes.Get("/").To(func(c *mojo.Context) { c.Render("hello.html.tmpl") })
+
+	app.Mode = "development"
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/").TextIs("Hello")
+	templates["hello.html.tmpl"] = &fstest.MapFile{Data: []byte("Goodbye")}
+	mt.GetOk("/").TextIs("Goodbye", "Templates are not cached in development mode")
+	mt.GetOk("/hello.txt")
+	if cc := mt.Context.Res.Headers.Header("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Development Cache-Control incorrect. Got: %s", cc)
+	}
+
+	app.Mode = "production"
+	mt.GetOk("/").TextIs("Goodbye")
+	templates["hello.html.tmpl"] = &fstest.MapFile{Data: []byte("Hello again")}
+	mt.GetOk("/").TextIs("Goodbye", "Templates are cached in production mode")
+	mt.GetOk("/hello.txt")
+	if cc := mt.Context.Res.Headers.Header("Cache-Control"); cc != "max-age=3600" {
+		t.Errorf("Production Cache-Control incorrect. Got: %s", cc)
+	}
+}
+
+func TestApplicationModeCachingConcurrent(t *testing.T) {
+	templates := fstest.MapFS{}
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("page%d.html.tmpl", i)
+		templates[name] = &fstest.MapFile{Data: []byte(name)}
+	}
+	app := mojo.NewApplication()
+	app.Mode = "production"
+	app.Renderer.AddFS(templates)
+	app.Routes.Get("/:page").To(func(c *mojo.Context) { c.Render(c.Param("page") + ".html.tmpl") })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := app.BuildContext(mojo.NewRequest("GET", fmt.Sprintf("/page%d", i)), nil)
+			app.Handler(c)
+			if body := c.Res.Content.String(); body != fmt.Sprintf("page%d.html.tmpl", i) {
+				t.Errorf("page%d: Got: %q", i, body)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestApplicationDefaults(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Defaults = mojo.Stash{"layout": "default", "lang": "en", "who": "World", "path": "/default"}
+	app.Renderer.AddTemplate("greet", "<% .Stash.layout %> <% .Stash.lang %> <% .Stash.who %> <% .Stash.path %>")
+	lang := app.Routes.Any(nil, "/:lang", mojo.Stash{"who": "Farnsworth"})
+	lang.Get("/hello", mojo.Stash{"lang": "fr", "who": "Fry"}).To(func(c *mojo.Context) { c.Render("greet") })
+	lang.Get("/render").To(func(c *mojo.Context) { c.Render("greet", mojo.Stash{"lang": "de"}) })
+	lang.Get("/parent").To(func(c *mojo.Context) { c.Render("greet") })
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/es/hello").StatusIs(200).TextIs("default es Fry /es/hello", "Captures override route defaults")
+	mt.GetOk("/es/render").StatusIs(200).TextIs("default de Farnsworth /es/render", "Render values override captures")
+	mt.GetOk("/es/parent").StatusIs(200).TextIs("default es Farnsworth /es/parent", "Route defaults override app defaults")
+
+	c := app.BuildContext(mojo.NewRequest("GET", "/"), nil)
+	c.Stash["layout"] = "changed"
+	if app.Defaults["layout"] != "default" {
+		t.Errorf("Changing the stash changed the application defaults")
+	}
+}
+
+func TestApplicationFinalize(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Static.Paths = []fs.FS{fstest.MapFS{"hello.txt": &fstest.MapFile{Data: []byte("Hello, Gophers!")}}}
+	app.Renderer.AddTemplate("report", `{"status":"ok"}`)
+	app.Routes.Get("/text").To(func(c *mojo.Context) {
+		c.Res.Headers.Add("X-Custom", "one", "two")
+		c.Res.Text("Hello, World!")
+	})
+	app.Routes.Get("/report").To(func(c *mojo.Context) { c.Render("report") })
+	app.Routes.Get("/raw").To(func(c *mojo.Context) { c.Res.Content = mojo.NewAsset("<p>Hi</p>") })
+	app.Routes.Get("/status").To(func(c *mojo.Context) { c.Res.Status = "418 I'm a teapot" })
+	app.Routes.Get("/blank").To(func(c *mojo.Context) { c.Res.Status = "  " })
+	app.Routes.Get("/empty").To(func(c *mojo.Context) { c.Res.Code = 204 })
+
+	tests := []struct {
+		path    string
+		headers map[string]string
+		code    int
+		expect  map[string]string
+		body    string
+	}{
+		{
+			path:   "/text",
+			code:   200,
+			expect: map[string]string{"Content-Type": "text/plain", "Content-Length": "13", "X-Custom": "one"},
+			body:   "Hello, World!",
+		},
+		{
+			path:   "/report.json",
+			code:   200,
+			expect: map[string]string{"Content-Type": mojo.DefaultTypes.Type("json"), "Content-Length": "15"},
+			body:   `{"status":"ok"}`,
+		},
+		{
+			path:   "/raw",
+			code:   200,
+			expect: map[string]string{"Content-Type": mojo.DefaultTypes.Type("html"), "Content-Length": "9"},
+			body:   "<p>Hi</p>",
+		},
+		{
+			path:   "/status",
+			code:   418,
+			expect: map[string]string{"Content-Length": "0"},
+		},
+		{
+			path:   "/blank",
+			code:   200,
+			expect: map[string]string{"Content-Length": "0"},
+		},
+		{
+			path:   "/empty",
+			code:   204,
+			expect: map[string]string{"Content-Length": "", "Content-Type": ""},
+		},
+		{
+			path:   "/hello.txt",
+			code:   200,
+			expect: map[string]string{"Content-Type": mojo.DefaultTypes.Type("txt"), "Content-Length": "15"},
+			body:   "Hello, Gophers!",
+		},
+		{
+			path:    "/hello.txt",
+			headers: map[string]string{"Range": "bytes=7-"},
+			code:    206,
+			expect:  map[string]string{"Content-Length": "8", "Content-Range": "bytes 7-14/15"},
+			body:    "Gophers!",
+		},
+		{
+			path:    "/hello.txt",
+			headers: map[string]string{"Range": "bytes=-8"},
+			code:    206,
+			expect:  map[string]string{"Content-Length": "8", "Content-Range": "bytes 7-14/15"},
+			body:    "Gophers!",
+		},
+		{
+			path:    "/hello.txt",
+			headers: map[string]string{"Range": "bytes=20-30"},
+			code:    416,
+			expect:  map[string]string{"Content-Length": "0", "Content-Range": "bytes */15"},
+		},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", tt.path, nil)
+		if err != nil {
+			t.Fatalf("Could not create HTTP request: %v", err)
+		}
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s: Status: %d; Expect: %d", tt.path, rec.Code, tt.code)
+		}
+		for name, value := range tt.expect {
+			if got := rec.Header().Get(name); got != value {
+				t.Errorf("%s: %s: %q; Expect: %q", tt.path, name, got, value)
+			}
+		}
+		if rec.Body.String() != tt.body {
+			t.Errorf("%s: Body: %q; Expect: %q", tt.path, rec.Body.String(), tt.body)
+		}
+	}
+}
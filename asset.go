@@ -43,6 +43,23 @@ func NewAsset(content interface{}) Asset {
 	panic(fmt.Sprintf("mojo.NewAsset: Unknown type %t", content))
 }
 
+// resolveRange returns the first and last byte of the given range for
+// content of the given size. A start of -1 requests the last end bytes,
+// and an end of -1 (or past the end of the content) requests everything
+// after start.
+func resolveRange(start int64, end int64, size int64) (int64, int64) {
+	if start < 0 {
+		start, end = size-end, size-1
+		if start < 0 {
+			start = 0
+		}
+	}
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+	return start, end
+}
+
 // FileAsset is an Asset backed by a file on a filesystem
 type FileAsset struct {
 	path     string
@@ -52,8 +69,19 @@ type FileAsset struct {
 	end      int64
 }
 
-// Length returns the length of the file
+// Length returns the length of the file, or the length of the range if
+// one is set
 func (asset *FileAsset) Length() int64 {
+	size := asset.size()
+	if asset.hasRange {
+		start, end := resolveRange(asset.start, asset.end, size)
+		return end - start + 1
+	}
+	return size
+}
+
+// size returns the size of the file
+func (asset *FileAsset) size() int64 {
 	stat, err := asset.file.Stat()
 	if err != nil {
 		panic(fmt.Sprintf("Could not Stat(): %v", err))
@@ -81,8 +109,7 @@ func (asset *FileAsset) open() io.Reader {
 	start := int64(0)
 	end := int64(-1)
 	if asset.hasRange {
-		start = asset.start
-		end = asset.end
+		start, end = resolveRange(asset.start, asset.end, asset.size())
 	}
 
 	file := asset.file.(io.ReadSeeker)
@@ -91,7 +118,7 @@ func (asset *FileAsset) open() io.Reader {
 	}
 
 	if end != -1 {
-		return &io.LimitedReader{file, end - start + 1}
+		return &io.LimitedReader{R: file, N: end - start + 1}
 	}
 	return file
 }
@@ -131,9 +158,15 @@ type MemoryAsset struct {
 	end      int64
 }
 
-// Length returns the length of the buffer
+// Length returns the length of the buffer, or the length of the range if
+// one is set
 func (asset *MemoryAsset) Length() int64 {
-	return int64(len(asset.buffer))
+	size := int64(len(asset.buffer))
+	if asset.hasRange {
+		start, end := resolveRange(asset.start, asset.end, size)
+		return end - start + 1
+	}
+	return size
 }
 
 // Range sets a start/end range to serve partial content
@@ -153,7 +186,8 @@ func (asset *MemoryAsset) Serve(w http.ResponseWriter) error {
 func (asset *MemoryAsset) String() string {
 	buffer := asset.buffer
 	if asset.hasRange {
-		buffer = buffer[asset.start : asset.end+1]
+		start, end := resolveRange(asset.start, asset.end, int64(len(buffer)))
+		buffer = buffer[start : end+1]
 	}
 	return string(buffer)
 }
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"sort"
+	"strings"
 )
 
 // Command is an interface for application commands. Types that
@@ -119,3 +120,81 @@ func (cmd *VersionCommand) Run(args []string) error {
 	fmt.Printf("The current version")
 	return nil
 }
+
+// RoutesCommand shows the application's routes
+type RoutesCommand struct {
+	App *Application
+}
+
+// Description returns the short description of the command's function
+func (cmd *RoutesCommand) Description() string {
+	return "Show available routes"
+}
+
+// Usage returns the full documentation for the command
+func (cmd *RoutesCommand) Usage() string {
+	return `[OPTIONS]
+
+OPTIONS
+  -v                               Show the regular expression and
+                                   conditions for each route
+`
+}
+
+// Run displays a tree of all the routes with their methods and names.
+// Nested routes are indented under the routes they are nested in.
+func (cmd *RoutesCommand) Run(args []string) error {
+	verbose := false
+	for _, arg := range args {
+		if arg != "-v" {
+			return fmt.Errorf("Unknown option: %s", arg)
+		}
+		verbose = true
+	}
+
+	rows := [][]string{}
+	cmd.App.Routes.walk(func(r *Route) bool {
+		path := r.path
+		if depth := len(r.chain()) - 1; depth > 0 {
+			path = strings.Repeat("  ", depth) + "+" + path
+		}
+		methods := "*"
+		if len(r.Methods) > 0 {
+			methods = strings.Join(r.Methods, ",")
+		}
+		row := []string{path, methods, r.Name}
+		if verbose {
+			pattern := r.Pattern
+			if len(r.routes) > 0 {
+				pattern = r.prefix
+			}
+			conditions := []string{}
+			for _, cond := range r.conditions {
+				conditions = append(conditions, fmt.Sprintf("%s=%v", cond.name, cond.args))
+			}
+			row = append(row, pattern.String(), strings.Join(conditions, " "))
+		}
+		rows = append(rows, row)
+		return true
+	})
+
+	widths := []int{}
+	for _, row := range rows {
+		for i, col := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if len(col) > widths[i] {
+				widths[i] = len(col)
+			}
+		}
+	}
+	for _, row := range rows {
+		line := ""
+		for i, col := range row {
+			line += fmt.Sprintf("%-*s  ", widths[i], col)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	return nil
+}
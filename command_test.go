@@ -0,0 +1,83 @@
+package mojo_test
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/preaction/mojo.go"
+)
+
+// captureStdout returns everything written to os.Stdout while running
+// the given function
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Could not create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Could not read output: %v", err)
+	}
+	return string(out)
+}
+
+func TestRoutesCommand(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/").To(func(*mojo.Context) {})
+	admin := app.Routes.Under("/admin", func(*mojo.Context) bool { return true })
+	admin.Post("/users/:id").Named("update_user").Requires("host", "example.com")
+	app.Routes.Any(nil, "/any")
+
+	cmd, ok := app.Commands["routes"]
+	if !ok {
+		t.Fatalf("routes command not registered")
+	}
+	if cmd.Description() == "" || cmd.Usage() == "" {
+		t.Errorf("routes command has no documentation")
+	}
+
+	t.Run("Default output", func(t *testing.T) {
+		var err error
+		out := captureStdout(t, func() { err = cmd.Run([]string{}) })
+		if err != nil {
+			t.Fatalf("Error running command: %v", err)
+		}
+		expect := "/              GET\n" +
+			"/admin         *     admin\n" +
+			"  +/users/:id  POST  update_user\n" +
+			"/any           *     any\n"
+		if out != expect {
+			t.Errorf("Output:\n%s\nExpect:\n%s", out, expect)
+		}
+	})
+
+	t.Run("Verbose output", func(t *testing.T) {
+		out := captureStdout(t, func() { cmd.Run([]string{"-v"}) })
+		lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("Expected 4 lines, got %d:\n%s", len(lines), out)
+		}
+		if !strings.Contains(lines[1], "^/admin") {
+			t.Errorf("Missing regexp for group route: %q", lines[1])
+		}
+		if !regexp.MustCompile(`\^/users/\(\?P<id>.+host=example\.com$`).MatchString(lines[2]) {
+			t.Errorf("Missing regexp or conditions for endpoint: %q", lines[2])
+		}
+	})
+
+	t.Run("Unknown option", func(t *testing.T) {
+		if err := cmd.Run([]string{"-x"}); err == nil {
+			t.Errorf("No error for unknown option")
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package mojo
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Condition is a function that decides whether a Route matches the
+// current request. Conditions are given the arguments passed to
+// Route.Requires.
+type Condition func(c *Context, r *Route, args interface{}) bool
+
+// routeCondition is a condition added to a route with Requires
+type routeCondition struct {
+	name      string
+	args      interface{}
+	condition Condition
+}
+
+// defaultConditions are the conditions available to every Routes
+// object. See: AddCondition
+var defaultConditions = map[string]Condition{
+	"host":    hostCondition,
+	"headers": headersCondition,
+	"agent":   agentCondition,
+}
+
+// AddCondition registers a condition with the given name. Routes can
+// then require the condition with Route.Requires. Conditions must be
+// added before any routes that use them.
+func (rs *Routes) AddCondition(name string, condition Condition) {
+	if rs.conditions == nil {
+		rs.conditions = map[string]Condition{}
+	}
+	rs.conditions[name] = condition
+}
+
+// lookupCondition finds the condition with the given name in these
+// routes or any parent routes. Returns nil if the condition is not
+// found.
+func (rs *Routes) lookupCondition(name string) Condition {
+	for ; rs != nil; rs = rs.parentRoutes() {
+		if condition, ok := rs.conditions[name]; ok {
+			return condition
+		}
+	}
+	return defaultConditions[name]
+}
+
+// Requires adds a condition to the route. The route only matches if the
+// condition passes. Conditions are checked after the method and path
+// match. The built-in conditions are:
+//
+//	host    - The Host header (without the port) must equal the given
+//	          string (case-insensitive) or match the given
+//	          *regexp.Regexp
+//	headers - A map[string]string or map[string]*regexp.Regexp of
+//	          headers that must equal or match the given values
+//	agent   - The User-Agent header must contain the given string or
+//	          match the given *regexp.Regexp
+//
+// More conditions can be added with Routes.AddCondition.
+func (r *Route) Requires(name string, args interface{}) *Route {
+	condition := r.parent.lookupCondition(name)
+	if condition == nil {
+		panic(fmt.Sprintf("Unknown route condition %q", name))
+	}
+	r.conditions = append(r.conditions, routeCondition{name: name, args: args, condition: condition})
+	return r
+}
+
+// checkConditions returns true if all of the route's conditions pass for
+// the given context
+func (r *Route) checkConditions(c *Context) bool {
+	for _, cond := range r.conditions {
+		if !cond.condition(c, r, cond.args) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchValue returns true if the given value equals the given string or
+// matches the given *regexp.Regexp.
+func matchValue(value string, want interface{}) bool {
+	switch v := want.(type) {
+	case string:
+		return value == v
+	case *regexp.Regexp:
+		return v.MatchString(value)
+	}
+	panic(fmt.Sprintf("Unknown condition value type %T", want))
+}
+
+// hostCondition is the "host" condition. See: Requires
+func hostCondition(c *Context, r *Route, args interface{}) bool {
+	host := c.Req.Headers.Header("Host")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if str, ok := args.(string); ok {
+		return host == strings.ToLower(str)
+	}
+	return matchValue(host, args)
+}
+
+// headersCondition is the "headers" condition. See: Requires
+func headersCondition(c *Context, r *Route, args interface{}) bool {
+	headers := map[string]interface{}{}
+	switch args := args.(type) {
+	case map[string]string:
+		for name, want := range args {
+			headers[name] = want
+		}
+	case map[string]*regexp.Regexp:
+		for name, want := range args {
+			headers[name] = want
+		}
+	case map[string]interface{}:
+		headers = args
+	default:
+		panic(fmt.Sprintf("Unknown headers condition type %T", args))
+	}
+	for name, want := range headers {
+		if !c.Req.Headers.Exists(name) || !matchValue(c.Req.Headers.Header(name), want) {
+			return false
+		}
+	}
+	return true
+}
+
+// agentCondition is the "agent" condition. See: Requires
+func agentCondition(c *Context, r *Route, args interface{}) bool {
+	agent := c.Req.Headers.Header("User-Agent")
+	if str, ok := args.(string); ok {
+		return strings.Contains(agent, str)
+	}
+	return matchValue(agent, args)
+}
@@ -0,0 +1,117 @@
+package mojo_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/preaction/mojo.go"
+	"github.com/preaction/mojo.go/testmojo"
+)
+
+func TestRouteRequiresHost(t *testing.T) {
+	got := ""
+	router := &mojo.Routes{}
+	router.Get("/").Requires("host", "example.com").To(func(*mojo.Context) { got = "example" })
+	router.Get("/").Requires("host", regexp.MustCompile(`\.example\.org$`)).To(func(*mojo.Context) { got = "org" })
+	router.Get("/").To(func(*mojo.Context) { got = "default" })
+
+	tests := map[string]string{
+		"example.com":      "example",
+		"EXAMPLE.COM:3000": "example",
+		"www.example.org":  "org",
+		"example.net":      "default",
+	}
+	for host, expect := range tests {
+		got = ""
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", "/"))
+		c.Req.Headers.Add("Host", host)
+		router.Dispatch(c)
+		if got != expect {
+			t.Errorf("Host %s: Got: %q; Expect: %q", host, got, expect)
+		}
+	}
+}
+
+func TestRouteRequiresHeaders(t *testing.T) {
+	router := &mojo.Routes{}
+	router.Get("/api").Requires("headers", map[string]string{"X-API-Version": "2"})
+	router.Get("/feed").Requires("headers", map[string]*regexp.Regexp{"Accept": regexp.MustCompile(`application/atom\+xml`)})
+
+	c := testmojo.NewContext(t, mojo.NewRequest("GET", "/api"))
+	c.Req.Headers.Add("X-API-Version", "2")
+	router.Dispatch(c)
+	if c.Match == nil {
+		t.Errorf("Route with matching header did not match")
+	}
+
+	c = testmojo.NewContext(t, mojo.NewRequest("GET", "/api"))
+	c.Req.Headers.Add("X-API-Version", "1")
+	router.Dispatch(c)
+	if c.Match != nil {
+		t.Errorf("Route with wrong header matched")
+	}
+
+	c = testmojo.NewContext(t, mojo.NewRequest("GET", "/feed"))
+	c.Req.Headers.Add("Accept", "text/html, application/atom+xml")
+	router.Dispatch(c)
+	if c.Match == nil {
+		t.Errorf("Route with matching header regexp did not match")
+	}
+
+	c = testmojo.NewContext(t, mojo.NewRequest("GET", "/feed"))
+	router.Dispatch(c)
+	if c.Match != nil {
+		t.Errorf("Route with missing header matched")
+	}
+}
+
+func TestRouteRequiresAgent(t *testing.T) {
+	router := &mojo.Routes{}
+	router.Get("/").Requires("agent", "Firefox")
+
+	c := testmojo.NewContext(t, mojo.NewRequest("GET", "/"))
+	c.Req.Headers.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Gecko/20100101 Firefox/115.0")
+	router.Dispatch(c)
+	if c.Match == nil {
+		t.Errorf("Route with matching User-Agent did not match")
+	}
+
+	c = testmojo.NewContext(t, mojo.NewRequest("GET", "/"))
+	c.Req.Headers.Add("User-Agent", "curl/8.0")
+	router.Dispatch(c)
+	if c.Match != nil {
+		t.Errorf("Route with wrong User-Agent matched")
+	}
+}
+
+func TestRoutesAddCondition(t *testing.T) {
+	router := &mojo.Routes{}
+	router.AddCondition("param", func(c *mojo.Context, r *mojo.Route, args interface{}) bool {
+		return c.Req.Param(args.(string)) != ""
+	})
+	api := router.Under("/api", func(*mojo.Context) bool { return true })
+	api.Get("/search").Requires("param", "q")
+
+	req := mojo.NewRequest("GET", "/api/search")
+	req.Params = mojo.Parameters{"q": []string{"mojo"}}
+	c := testmojo.NewContext(t, req)
+	router.Dispatch(c)
+	if c.Match == nil {
+		t.Errorf("Route with passing custom condition did not match")
+	}
+
+	c = testmojo.NewContext(t, mojo.NewRequest("GET", "/api/search"))
+	router.Dispatch(c)
+	if c.Match != nil {
+		t.Errorf("Route with failing custom condition matched")
+	}
+
+	t.Run("Unknown condition panics", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Unknown condition did not panic")
+			}
+		}()
+		router.Get("/").Requires("unknown", nil)
+	})
+}
@@ -1,5 +1,14 @@
 package mojo
 
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
 // Stash is a place to store arbitrary data during a request.
 type Stash map[string]interface{}
 
@@ -20,6 +29,25 @@ type Context struct {
 	App              *Application
 	rendered         bool
 	continueDispatch bool
+	basePath         string
+	canonicalPath    string
+	streaming        bool
+
+	// Delayed responses. See: RenderLater
+	lock       sync.Mutex
+	later      bool
+	done       chan struct{}
+	doneOnce   sync.Once
+	activity   chan struct{}
+	inactivity time.Duration
+	abandoned  bool
+
+	// Sessions. See: Session
+	session        Session
+	sessionID      string
+	sessionCookie  bool
+	sessionExpired bool
+	sessionStored  bool
 }
 
 // Param returns the given parameter. Stash values take precedence over
@@ -35,11 +63,30 @@ func (c *Context) Param(name string) string {
 
 // Render finalizes and writes the response to the client. The given
 // Stash will be merged with the stash inside the context to produce the
-// response.
+// response. Render can be called from another goroutine after
+// RenderLater.
 func (c *Context) Render(templateName string, stash ...Stash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.abandoned {
+		return
+	}
+	defer c.finishLater()
+
 	if templateName != "" {
 		str := c.RenderToString(templateName, stash...)
 		c.Res.Content = NewAsset(str)
+		// Use the template's format (or the detected format) for the
+		// Content-Type
+		if !c.Res.Headers.Exists("Content-Type") {
+			format := c.templateFormat(templateName)
+			if format == "" {
+				format, _ = c.Stash["format"].(string)
+			}
+			if t := c.types().Type(format); t != "" {
+				c.Res.Headers.Add("Content-Type", t)
+			}
+		}
 	}
 	// Reserved stashes:
 	// status -> c.Res.Code
@@ -49,6 +96,103 @@ func (c *Context) Render(templateName string, stash ...Stash) {
 	c.rendered = true
 }
 
+// NotFound renders the "not_found" template with a 404 Not Found
+// status. Templates are looked up by mode and format, then by format,
+// using the "format" stash value and then "html":
+//
+//	not_found.development.json.tmpl
+//	not_found.json.tmpl
+//	not_found.development.html.tmpl
+//	not_found.html.tmpl
+//
+// The built-in "not_found.html.tmpl" template shows the request in
+// "development" mode and a plain page in other modes.
+func (c *Context) NotFound() {
+	c.Res.Code = 404
+	c.Res.Status = "Not Found"
+	c.renderStatus("not_found", 404)
+}
+
+// Exception logs the given error and renders the "exception" template
+// with a 500 Internal Server Error status. The template gets an
+// *Exception in the "exception" stash value, with the location the
+// error was reported from (or where the panic happened, for panics
+// recovered by Application.Handler). Templates are looked up like
+// NotFound. The built-in "exception.html.tmpl" template shows the error,
+// the source code around it, and the request in "development" mode, and
+// a plain page in other modes.
+func (c *Context) Exception(err error) {
+	e := newException(err, 1)
+	if c.App != nil {
+		c.App.Log.Error("%s at %s line %d\n%s", e.Message, e.File, e.Line, e.Stack)
+	}
+
+	// The response can't be changed after it has started streaming or
+	// after it was abandoned
+	c.lock.Lock()
+	stop := c.streaming || c.abandoned
+	c.lock.Unlock()
+	if stop {
+		return
+	}
+
+	c.Res.Headers = Headers{}
+	c.Res.Code = 500
+	c.Res.Status = "Internal Server Error"
+	c.Stash["exception"] = e
+	defer func() {
+		if r := recover(); r != nil {
+			if c.App != nil {
+				c.App.Log.Error("Could not render exception template: %v", r)
+			}
+			c.Res.Text("Internal Server Error")
+			c.rendered = true
+		}
+	}()
+	c.renderStatus("exception", 500)
+}
+
+// renderStatus renders the first template found for the given name
+// with the given status. See: NotFound. Contexts without an Application
+// only get the status. Renderers that cannot check for templates always
+// render the "html" template without a mode.
+func (c *Context) renderStatus(name string, status int) {
+	if c.App == nil || c.App.Renderer == nil {
+		return
+	}
+	checker, ok := c.App.Renderer.(templateChecker)
+	if !ok {
+		c.Res.Headers["Content-Type"] = []string{c.types().Type("html")}
+		c.Render(name+".html.tmpl", Stash{"status": status})
+		return
+	}
+	formats := []string{"html"}
+	if format, ok := c.Stash["format"].(string); ok && format != "html" {
+		formats = append([]string{format}, formats...)
+	}
+	for _, format := range formats {
+		for _, tmpl := range []string{
+			fmt.Sprintf("%s.%s.%s.tmpl", name, c.App.Mode, format),
+			fmt.Sprintf("%s.%s.tmpl", name, format),
+		} {
+			if !checker.HasTemplate(tmpl) {
+				continue
+			}
+			if t := c.types().Type(format); t != "" {
+				c.Res.Headers["Content-Type"] = []string{t}
+			}
+			c.Render(tmpl, Stash{"status": status})
+			return
+		}
+	}
+}
+
+// Stop stops dispatch. No more route handlers or middleware are called
+// for the current request. See: Routes.Dispatch
+func (c *Context) Stop() {
+	c.continueDispatch = false
+}
+
 // RenderToString returns the rendered output as a string. It does not
 // write anything to the response.
 func (c *Context) RenderToString(templateName string, stash ...Stash) string {
@@ -57,3 +201,193 @@ func (c *Context) RenderToString(templateName string, stash ...Stash) string {
 	}
 	return c.App.Renderer.Render(templateName, c)
 }
+
+// URLFor returns the path for the route with the given name, filling in
+// placeholders from the given Stash and the route's default values. If
+// no route has the given name, the name is returned as the path. If the
+// application is mounted inside another application, the path includes
+// the prefix it is mounted under. See: Routes.Mount
+func (c *Context) URLFor(name string, values Stash) string {
+	path := name
+	if r := c.App.Routes.Lookup(name); r != nil {
+		path = r.URL(values)
+	}
+	return c.basePath + path
+}
+
+// templateFormat returns the format in the given template name, like
+// "json" for "report.json.tmpl", or the empty string if the name does
+// not have a registered format
+func (c *Context) templateFormat(name string) string {
+	name = strings.TrimSuffix(name, ".tmpl")
+	if slash := strings.LastIndexByte(name, '/'); slash >= 0 {
+		name = name[slash+1:]
+	}
+	dot := strings.LastIndexByte(name, '.')
+	if dot < 0 || c.types().Type(name[dot+1:]) == "" {
+		return ""
+	}
+	return name[dot+1:]
+}
+
+// types returns the Application's Types registry, or DefaultTypes if
+// there is none
+func (c *Context) types() *Types {
+	if c.App == nil || c.App.Types == nil {
+		return DefaultTypes
+	}
+	return c.App.Types
+}
+
+// Write writes the given data to the client immediately, without
+// chunked transfer encoding, and calls the callback (if any) after the
+// data has been flushed. The response headers are sent with the first
+// write, and the connection is closed when the response ends. The
+// response ends when the handler returns. See: WriteChunk
+//
+// Writing marks the context as rendered, so Application.Handler does
+// not write the response body again. Contexts without an
+// http.ResponseWriter add the data to the response content instead.
+//
+// Returns an error (and does not call the callback) if the data could
+// not be written, like when the client has disconnected. The response
+// is then abandoned, so later writes fail too. See: RenderLater
+func (c *Context) Write(data []byte, callback func(*Context)) error {
+	if err := c.write(data, false); err != nil {
+		return err
+	}
+	if callback != nil {
+		callback(c)
+	}
+	return nil
+}
+
+// WriteChunk writes the given data to the client immediately with
+// chunked transfer encoding, and calls the callback (if any) after the
+// data has been flushed. Otherwise it works like Write.
+func (c *Context) WriteChunk(data []byte, callback func(*Context)) error {
+	if err := c.write(data, true); err != nil {
+		return err
+	}
+	if callback != nil {
+		callback(c)
+	}
+	return nil
+}
+
+// write sends the response headers (if they have not been sent yet) and
+// writes and flushes the given data. A failed write abandons the
+// response.
+func (c *Context) write(data []byte, chunked bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.abandoned {
+		return errAbandoned
+	}
+	c.touch()
+
+	if !c.streaming {
+		c.streaming = true
+		c.rendered = true
+		if !chunked {
+			// The http.ResponseWriter closes the connection instead
+			c.Res.Headers["Transfer-Encoding"] = []string{"identity"}
+		}
+		c.prepareHead(true)
+		if c.Res.Writer != nil {
+			c.writeHead()
+		}
+	}
+	if c.Res.Writer == nil {
+		c.Res.Content.AddChunk(data)
+		return nil
+	}
+	// Responses to HEAD requests have no body
+	if c.Req.Method != "HEAD" && len(data) > 0 {
+		if _, err := c.Res.Writer.Write(data); err != nil {
+			c.abandoned = true
+			return err
+		}
+	}
+	if flusher, ok := c.Res.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// finalize fills in the response status and headers and writes the
+// response to the http.ResponseWriter, if there is one.
+//
+// The status code comes from Res.Code, or from the number at the start
+// of Res.Status if Res.Code is not set, and defaults to 200. Res.Status
+// is filled in from the status code if it is not set. The
+// http.ResponseWriter always sends the standard status text.
+//
+// The Content-Type header defaults to the type for the "format" stash
+// value (or "html") if there is content. The Content-Length header is
+// set from the length of the content (or the requested range).
+func (c *Context) finalize() {
+	hasBody := c.prepareHead(false)
+	if c.Res.Writer == nil {
+		return
+	}
+	c.writeHead()
+	// Responses to HEAD requests have no body
+	if hasBody && c.Req.Method != "HEAD" {
+		c.Res.Content.Serve(c.Res.Writer)
+	}
+}
+
+// prepareHead fills in the response status and headers, including the
+// session cookie. Streamed responses do not get a Content-Length.
+// Returns true if the response can have a body. See: finalize
+func (c *Context) prepareHead(streaming bool) bool {
+	c.storeSession()
+	res := c.Res
+	res.Status = strings.TrimSpace(res.Status)
+	if fields := strings.Fields(res.Status); res.Code == 0 && len(fields) > 0 {
+		if code, err := strconv.Atoi(fields[0]); err == nil {
+			res.Code = code
+			res.Status = strings.TrimSpace(strings.TrimPrefix(res.Status, fields[0]))
+		}
+	}
+	if res.Code == 0 {
+		res.Code = 200
+	}
+	if res.Status == "" {
+		res.Status = http.StatusText(res.Code)
+	}
+
+	// Responses with 1xx, 204, and 304 status codes have no body
+	hasBody := res.Code >= 200 && res.Code != 204 && res.Code != 304
+	if !hasBody {
+		return false
+	}
+	length := int64(0)
+	if !streaming {
+		length = res.Content.Length()
+	}
+	if (streaming || length > 0) && !res.Headers.Exists("Content-Type") {
+		format, ok := c.Stash["format"].(string)
+		if !ok {
+			format = "html"
+		}
+		if t := c.types().Type(format); t != "" {
+			res.Headers.Add("Content-Type", t)
+		}
+	}
+	if !streaming && !res.Headers.Exists("Content-Length") {
+		res.Headers.Add("Content-Length", strconv.FormatInt(length, 10))
+	}
+	return true
+}
+
+// writeHead writes the response status and headers to the
+// http.ResponseWriter
+func (c *Context) writeHead() {
+	header := c.Res.Writer.Header()
+	for name, values := range c.Res.Headers {
+		header[http.CanonicalHeaderKey(name)] = values
+	}
+	c.Res.Writer.WriteHeader(c.Res.Code)
+}
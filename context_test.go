@@ -1,9 +1,14 @@
 package mojo_test
 
 import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/preaction/mojo.go"
+	"github.com/preaction/mojo.go/testmojo"
 )
 
 func TestContextParam(t *testing.T) {
@@ -36,3 +41,132 @@ func TestContextRenderToString(t *testing.T) {
 		t.Errorf(`RenderToString("foo") != "bar"; Got: %s`, out)
 	}
 }
+
+func TestContextURLFor(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/user/:id").Named("user")
+	app.Routes.Get("/data", mojo.Stash{"format": []string{"json"}}).Named("data")
+
+	c := app.BuildContext(mojo.NewRequest("GET", "/"), nil)
+	if url := c.URLFor("user", mojo.Stash{"id": "fry"}); url != "/user/fry" {
+		t.Errorf(`URLFor("user") != "/user/fry"; Got: %s`, url)
+	}
+	if url := c.URLFor("data", mojo.Stash{"format": "json"}); url != "/data.json" {
+		t.Errorf(`URLFor("data") != "/data.json"; Got: %s`, url)
+	}
+	if url := c.URLFor("/about", nil); url != "/about" {
+		t.Errorf(`URLFor("/about") != "/about"; Got: %s`, url)
+	}
+}
+
+func TestContextRenderFormat(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Renderer.AddTemplate("report", `{"status":"ok"}`)
+	app.Routes.Get("/report").To(func(c *mojo.Context) { c.Render("report") })
+
+	c := app.BuildContext(mojo.NewRequest("GET", "/report.json"), nil)
+	app.Handler(c)
+	if got := c.Res.Headers.Header("Content-Type"); got != mojo.DefaultTypes.Type("json") {
+		t.Errorf("Content-Type != %s; Got: %s", mojo.DefaultTypes.Type("json"), got)
+	}
+
+	// The template's format decides the Content-Type
+	app.Renderer.AddTemplate("hello.html.tmpl", `<p>Hello, <% .Stash.name %>!</p>`)
+	app.Routes.Get("/hello/:name").To(func(c *mojo.Context) { c.Render("hello.html.tmpl") })
+	c = app.BuildContext(mojo.NewRequest("GET", "/hello/fry.json"), nil)
+	app.Handler(c)
+	if got := c.Res.Headers.Header("Content-Type"); got != mojo.DefaultTypes.Type("html") {
+		t.Errorf("Content-Type != %s; Got: %s", mojo.DefaultTypes.Type("html"), got)
+	}
+
+	c = app.BuildContext(mojo.NewRequest("GET", "/hello/fry.exe"), nil)
+	app.Handler(c)
+	if c.Res.Code != 404 {
+		t.Errorf("Unregistered format matched route. Got: %d", c.Res.Code)
+	}
+}
+
+func TestContextWrite(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/write").To(func(c *mojo.Context) {
+		c.Res.Headers.Add("Content-Type", "text/plain")
+		c.Write([]byte("Hello, "), func(c *mojo.Context) {
+			c.Write([]byte("World!"), nil)
+		})
+	})
+	app.Routes.Get("/chunk").To(func(c *mojo.Context) {
+		for _, chunk := range []string{"one ", "two ", "three"} {
+			c.WriteChunk([]byte(chunk), nil)
+		}
+	})
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/write").StatusIs(200).BodyIs("Hello, World!")
+	mt.GetOk("/chunk").StatusIs(200).BodyIs("one two three")
+	if ct := mt.Context.Res.Headers.Header("Content-Type"); ct != mojo.DefaultTypes.Type("html") {
+		t.Errorf("Default Content-Type incorrect: %s", ct)
+	}
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+	for path, expect := range map[string]struct {
+		body    string
+		chunked bool
+	}{
+		"/write": {"Hello, World!", false},
+		"/chunk": {"one two three", true},
+	} {
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("%s: Could not get: %v", path, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != expect.body {
+			t.Errorf("%s: Body: %q; Expect: %q", path, body, expect.body)
+		}
+		chunked := len(res.TransferEncoding) > 0 && res.TransferEncoding[0] == "chunked"
+		if chunked != expect.chunked {
+			t.Errorf("%s: Chunked: %v; Expect: %v", path, chunked, expect.chunked)
+		}
+		if res.ContentLength != -1 {
+			t.Errorf("%s: Streamed response has Content-Length %d", path, res.ContentLength)
+		}
+	}
+
+	// Without a ResponseWriter, written data is added to the content
+	c := app.BuildContext(mojo.NewRequest("GET", "/chunk"), nil)
+	app.Handler(c)
+	if c.Res.Content.String() != "one two three" {
+		t.Errorf("Content: %q", c.Res.Content.String())
+	}
+}
+
+// failingWriter is an http.ResponseWriter that fails to write, like when
+// the client has disconnected
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write(data []byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestContextWriteError(t *testing.T) {
+	app := mojo.NewApplication()
+	errs := []error{}
+	called := false
+	app.Routes.Get("/write").To(func(c *mojo.Context) {
+		errs = append(errs, c.WriteChunk([]byte("one"), func(c *mojo.Context) { called = true }))
+		errs = append(errs, c.Write([]byte("two"), nil))
+	})
+
+	c := app.BuildContext(mojo.NewRequest("GET", "/write"), mojo.NewResponse(failingWriter{httptest.NewRecorder()}))
+	app.Handler(c)
+	if len(errs) != 2 || errs[0] == nil || errs[1] == nil {
+		t.Errorf("Failed writes did not return errors: %v", errs)
+	}
+	if called {
+		t.Errorf("Callback called after failed write")
+	}
+}
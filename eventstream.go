@@ -0,0 +1,93 @@
+package mojo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event is a Server-Sent Event. See: EventStream
+type Event struct {
+	// ID sets the client's last event ID, which is sent back in the
+	// Last-Event-ID header when the client reconnects
+	ID string
+	// Event is the event type. Clients get events without a type as
+	// "message" events.
+	Event string
+	// Data is the event data. Data with multiple lines is sent as
+	// multiple "data" fields.
+	Data string
+	// Retry sets how long the client waits before reconnecting
+	Retry time.Duration
+}
+
+// String returns the event in the text/event-stream format
+func (e Event) String() string {
+	str := strings.Builder{}
+	if e.ID != "" {
+		fmt.Fprintf(&str, "id: %s\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&str, "event: %s\n", e.Event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&str, "retry: %d\n", e.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(e.Data, "\n") {
+		fmt.Fprintf(&str, "data: %s\n", line)
+	}
+	str.WriteString("\n")
+	return str.String()
+}
+
+// EventStream sends Server-Sent Events to the client. Create one with
+// Context.EventStream.
+type EventStream struct {
+	Context *Context
+}
+
+// EventStream starts a Server-Sent Events response. The response
+// headers are sent immediately with a "text/event-stream" Content-Type
+// and headers to disable caching and proxy buffering. Send events with
+// the returned EventStream until the client disconnects:
+//
+//	stream := c.EventStream()
+//	for {
+//		select {
+//		case <-stream.Done():
+//			return
+//		case msg := <-messages:
+//			stream.Send(mojo.Event{Event: "message", Data: msg})
+//		}
+//	}
+func (c *Context) EventStream() *EventStream {
+	c.Res.Headers["Content-Type"] = []string{"text/event-stream"}
+	c.Res.Headers["Cache-Control"] = []string{"no-cache"}
+	c.Res.Headers["X-Accel-Buffering"] = []string{"no"}
+	c.WriteChunk(nil, nil)
+	return &EventStream{Context: c}
+}
+
+// Done returns a channel that is closed when the client disconnects.
+// See: Request.Context
+func (s *EventStream) Done() <-chan struct{} {
+	return s.Context.Req.Context().Done()
+}
+
+// Send sends the given event to the client. Returns an error if the
+// client has disconnected.
+func (s *EventStream) Send(e Event) error {
+	if err := s.Context.Req.Context().Err(); err != nil {
+		return err
+	}
+	return s.Context.write([]byte(e.String()), true)
+}
+
+// EventSource creates a GET route that responds with a Server-Sent
+// Events stream. The handler is given the EventStream and should send
+// events until the client disconnects. See: Context.EventStream
+func (rs *Routes) EventSource(pattern string, handler func(*Context, *EventStream), opts ...interface{}) *Route {
+	return rs.Get(pattern, opts...).To(func(c *Context) {
+		handler(c, c.EventStream())
+	})
+}
@@ -0,0 +1,95 @@
+package mojo_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/preaction/mojo.go"
+	"github.com/preaction/mojo.go/testmojo"
+)
+
+func TestEventString(t *testing.T) {
+	tests := map[string]mojo.Event{
+		"data: hello\n\n": {Data: "hello"},
+		"id: 1\nevent: update\nretry: 1500\ndata: {}\n\n": {ID: "1", Event: "update", Data: "{}", Retry: 1500 * time.Millisecond},
+		"data: line one\ndata: line two\n\n":              {Data: "line one\nline two"},
+	}
+	for expect, event := range tests {
+		if got := event.String(); got != expect {
+			t.Errorf("Got: %q; Expect: %q", got, expect)
+		}
+	}
+}
+
+func TestContextEventStream(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.EventSource("/events", func(c *mojo.Context, stream *mojo.EventStream) {
+		stream.Send(mojo.Event{Data: "hello", Retry: time.Second})
+		stream.Send(mojo.Event{ID: "2", Event: "update", Data: "one\ntwo"})
+	})
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/events").StatusIs(200).EventsAre([]mojo.Event{
+		{Data: "hello", Retry: time.Second},
+		{ID: "2", Event: "update", Data: "one\ntwo"},
+	})
+	for name, expect := range map[string]string{"Cache-Control": "no-cache", "X-Accel-Buffering": "no"} {
+		if got := mt.Context.Res.Headers.Header(name); got != expect {
+			t.Errorf("%s: %q; Expect: %q", name, got, expect)
+		}
+	}
+}
+
+func TestContextEventStreamDisconnect(t *testing.T) {
+	disconnected := make(chan bool, 1)
+	tick := make(chan string)
+	app := mojo.NewApplication()
+	app.Routes.EventSource("/events", func(c *mojo.Context, stream *mojo.EventStream) {
+		for {
+			select {
+			case <-stream.Done():
+				disconnected <- stream.Send(mojo.Event{Data: "gone"}) != nil
+				return
+			case msg := <-tick:
+				stream.Send(mojo.Event{Data: msg})
+			}
+		}
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatalf("Could not get: %v", err)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type: %s", ct)
+	}
+	body := bufio.NewReader(res.Body)
+	tick <- "first"
+	event := ""
+	for !strings.HasSuffix(event, "\n\n") {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Could not read event: %v", err)
+		}
+		event += line
+	}
+	if event != "data: first\n\n" {
+		t.Errorf("Event: %q", event)
+	}
+	res.Body.Close()
+
+	select {
+	case sendFailed := <-disconnected:
+		if !sendFailed {
+			t.Errorf("Send after disconnect did not return an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Handler did not see the client disconnect")
+	}
+}
@@ -0,0 +1,94 @@
+package mojo
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Exception is an error recovered from a panic in a handler, with
+// information about where it happened. Exceptions are given to the
+// "exception" template in the "exception" stash value.
+type Exception struct {
+	Message string
+	Value   interface{}
+	File    string
+	Line    int
+	Func    string
+	Source  []SourceLine
+	Stack   string
+}
+
+// SourceLine is a line of source code around the location of an
+// Exception
+type SourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// exceptionContext is the number of source lines to show before and
+// after the line where an exception happened
+const exceptionContext = 3
+
+// newException builds an Exception from the given value, finding the
+// location from the stack of the caller skip frames up. If the stack
+// contains a panic, the location is where the panic happened.
+func newException(value interface{}, skip int) *Exception {
+	if e, ok := value.(*Exception); ok {
+		return e
+	}
+	e := &Exception{Value: value, Stack: string(debug.Stack())}
+	if err, ok := value.(error); ok {
+		e.Message = err.Error()
+	} else {
+		e.Message = fmt.Sprint(value)
+	}
+
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(skip+2, pcs)])
+	location := runtime.Frame{}
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			// Start again after the panic
+			location = runtime.Frame{}
+		} else if location.PC == 0 && !strings.HasPrefix(frame.Function, "runtime.") {
+			location = frame
+		}
+		if !more {
+			break
+		}
+	}
+	e.File, e.Line, e.Func = location.File, location.Line, location.Function
+	e.Source = sourceLines(e.File, e.Line)
+	return e
+}
+
+// sourceLines returns the lines of the given file around the given line
+// number, or nil if the file cannot be read
+func sourceLines(file string, line int) []SourceLine {
+	if file == "" {
+		return nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(string(content), "\n")
+	source := []SourceLine{}
+	for i := line - exceptionContext; i <= line+exceptionContext; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		source = append(source, SourceLine{Number: i, Text: lines[i-1], Current: i == line})
+	}
+	return source
+}
+
+// Error returns the exception message
+func (e *Exception) Error() string {
+	return e.Message
+}
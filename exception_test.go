@@ -0,0 +1,136 @@
+package mojo_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/preaction/mojo.go"
+	"github.com/preaction/mojo.go/testmojo"
+)
+
+func newExceptionApp(mode string) (*mojo.Application, *strings.Builder) {
+	app := mojo.NewApplication()
+	app.Mode = mode
+	log := &strings.Builder{}
+	app.Log.Handle = log
+	app.Routes.Get("/panic").To(func(c *mojo.Context) {
+		c.Stash["who"] = "Fry"
+		panic(errors.New("Bad news, everyone"))
+	})
+	app.Routes.Get("/index").To(func(c *mojo.Context) {
+		list := []string{}
+		c.Res.Text(list[1])
+	})
+	return app, log
+}
+
+func TestApplicationExceptionDevelopment(t *testing.T) {
+	app, log := newExceptionApp("development")
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/panic").StatusIs(500)
+
+	body := mt.Context.Res.Content.String()
+	for _, expect := range []string{
+		"<h1>Bad news, everyone</h1>",
+		"exception_test.go line 19",
+		`<tr class="current"><td class="key">19</td><td><pre>		panic(errors.New(&#34;Bad news, everyone&#34;))</pre></td></tr>`,
+		`<td class="key">who</td><td><pre>&#34;Fry&#34;</pre>`,
+		"<h2>GET /panic</h2>",
+		"goroutine",
+	} {
+		if !strings.Contains(body, expect) {
+			t.Errorf("Exception page missing %q:\n%s", expect, body)
+		}
+	}
+	if ct := mt.Context.Res.Headers.Header("Content-Type"); ct != mojo.DefaultTypes.Type("html") {
+		t.Errorf("Incorrect Content-Type: %s", ct)
+	}
+	if !strings.Contains(log.String(), "[error] Bad news, everyone at ") || !strings.Contains(log.String(), "goroutine") {
+		t.Errorf("Exception not logged with stack trace: %s", log.String())
+	}
+
+	mt.GetOk("/index").StatusIs(500)
+	if !strings.Contains(mt.Context.Res.Content.String(), "exception_test.go line 23") {
+		t.Errorf("Runtime error location not found:\n%s", mt.Context.Res.Content.String())
+	}
+}
+
+func TestApplicationExceptionProduction(t *testing.T) {
+	app, _ := newExceptionApp("production")
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/panic").StatusIs(500)
+
+	body := mt.Context.Res.Content.String()
+	if !strings.Contains(body, "<h1>Internal Server Error</h1>") {
+		t.Errorf("Production exception page incorrect:\n%s", body)
+	}
+	if strings.Contains(body, "Bad news") || strings.Contains(body, "goroutine") {
+		t.Errorf("Production exception page shows details:\n%s", body)
+	}
+}
+
+func TestApplicationExceptionTemplate(t *testing.T) {
+	app, _ := newExceptionApp("production")
+	app.Renderer.AddTemplate("exception.html.tmpl", "Oops: <% .Stash.exception.Message %>")
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/panic").StatusIs(500).TextIs("Oops: Bad news, everyone")
+}
+
+func TestContextException(t *testing.T) {
+	app, log := newExceptionApp("development")
+	app.Routes.Get("/error").To(func(c *mojo.Context) {
+		c.Exception(errors.New("Handler error"))
+	})
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/error").StatusIs(500)
+	body := mt.Context.Res.Content.String()
+	if !strings.Contains(body, "<h1>Handler error</h1>") || !strings.Contains(body, "exception_test.go line 83") {
+		t.Errorf("Exception page incorrect:\n%s", body)
+	}
+	if !strings.Contains(log.String(), "[error] Handler error at ") {
+		t.Errorf("Exception not logged: %s", log.String())
+	}
+
+	app.Renderer.AddTemplate("exception.development.html.tmpl", "Dev: <% .Stash.exception.Message %>")
+	app.Renderer.AddTemplate("exception.json.tmpl", `{"error":"<% .Stash.exception.Message %>"}`)
+	mt.GetOk("/error").StatusIs(500).TextIs("Dev: Handler error")
+	mt.GetOk("/error.json").StatusIs(500).TextIs(`{"error":"Handler error"}`)
+	if ct := mt.Context.Res.Headers.Header("Content-Type"); ct != mojo.DefaultTypes.Type("json") {
+		t.Errorf("Incorrect Content-Type: %s", ct)
+	}
+}
+
+func TestContextNotFound(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/user/:id").To(func(c *mojo.Context) {
+		if c.Stash["id"] != "1" {
+			c.NotFound()
+			return
+		}
+		c.Res.Text("Fry")
+	})
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/user/1").StatusIs(200).TextIs("Fry")
+	mt.GetOk("/user/2").StatusIs(404)
+	if !strings.Contains(mt.Context.Res.Content.String(), "<code>GET /user/2</code>") {
+		t.Errorf("Development not found page incorrect:\n%s", mt.Context.Res.Content.String())
+	}
+	mt.GetOk("/missing").StatusIs(404)
+	if !strings.Contains(mt.Context.Res.Content.String(), "<code>GET /missing</code>") {
+		t.Errorf("Unmatched route does not render not found page:\n%s", mt.Context.Res.Content.String())
+	}
+
+	app.Mode = "production"
+	mt.GetOk("/missing").StatusIs(404)
+	if body := mt.Context.Res.Content.String(); !strings.Contains(body, "<h1>Page not found</h1>") || strings.Contains(body, "/missing") {
+		t.Errorf("Production not found page incorrect:\n%s", body)
+	}
+
+	app.Renderer.AddTemplate("not_found.production.html.tmpl", "Production: <% .Stash.path %>")
+	app.Renderer.AddTemplate("not_found.html.tmpl", "Any: <% .Stash.path %>")
+	mt.GetOk("/missing").StatusIs(404).TextIs("Production: /missing")
+	app.Mode = "development"
+	mt.GetOk("/missing").StatusIs(404).TextIs("Any: /missing")
+}
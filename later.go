@@ -0,0 +1,153 @@
+package mojo
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultInactivityTimeout is how long Application.Handler waits for
+// a delayed response before giving up. See: Context.RenderLater
+const DefaultInactivityTimeout = 15 * time.Second
+
+// errAbandoned is returned when writing to a response that was
+// abandoned after an inactivity timeout or because the client
+// disconnected
+var errAbandoned = errors.New("response was abandoned")
+
+// RenderLater tells Application.Handler to wait for the response to be
+// rendered after the handler returns. Handlers can then start
+// goroutines to do slow work and call Render (or NotFound, Exception,
+// or Render("") after streaming with Write) from the goroutine when the
+// response is ready:
+//
+//	r.To(func(c *mojo.Context) {
+//		c.RenderLater()
+//		go func() {
+//			c.Stash["user"] = loadUser(c.Param("id"))
+//			c.Render("user.html.tmpl")
+//		}()
+//	})
+//
+// If there is no activity (rendering or writing) for the inactivity
+// timeout, Handler gives up and responds with 504 Gateway Timeout. If
+// the client disconnects, Handler gives up without a response. Either
+// way, later calls to Render and Write are ignored. Use
+// Req.Context().Done() to stop work for abandoned requests.
+func (c *Context) RenderLater() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.later || c.rendered {
+		return
+	}
+	c.later = true
+	c.done = make(chan struct{})
+	c.activity = make(chan struct{}, 1)
+}
+
+// InactivityTimeout sets how long Application.Handler waits without any
+// activity for a delayed response. Defaults to DefaultInactivityTimeout.
+// See: RenderLater
+func (c *Context) InactivityTimeout(timeout time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.inactivity = timeout
+	c.touch()
+}
+
+// touch signals activity on a delayed response. Must be called with the
+// lock held.
+func (c *Context) touch() {
+	if c.activity == nil {
+		return
+	}
+	select {
+	case c.activity <- struct{}{}:
+	default:
+	}
+}
+
+// finishLater signals that a delayed response is ready. Must be called
+// with the lock held.
+func (c *Context) finishLater() {
+	if c.done != nil {
+		c.doneOnce.Do(func() { close(c.done) })
+	}
+}
+
+// wait waits for a delayed response to be rendered. Returns an error if
+// the inactivity timeout passes or the client disconnects first, and
+// marks the response as abandoned.
+func (c *Context) wait() error {
+	c.lock.Lock()
+	later, done, activity := c.later, c.done, c.activity
+	c.lock.Unlock()
+	if !later {
+		return nil
+	}
+
+	var err error
+	timer := time.NewTimer(c.inactivityTimeout())
+	defer timer.Stop()
+	for err == nil {
+		select {
+		case <-done:
+			return nil
+		case <-activity:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(c.inactivityTimeout())
+		case <-timer.C:
+			err = errors.New("Inactivity timeout")
+		case <-c.Req.Context().Done():
+			err = c.Req.Context().Err()
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// The response may have been rendered while we timed out
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+	c.abandoned = true
+	return err
+}
+
+// inactivityTimeout returns the inactivity timeout for a delayed
+// response
+func (c *Context) inactivityTimeout() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.inactivity > 0 {
+		return c.inactivity
+	}
+	return DefaultInactivityTimeout
+}
+
+// timeout finishes an abandoned delayed response. Responses that timed
+// out get a 504 Gateway Timeout, unless they already started streaming.
+// Returns false if the response should not be written, because it
+// already started streaming or the client disconnected.
+func (c *Context) timeout(err error) bool {
+	if c.Req.Context().Err() != nil {
+		if c.App != nil {
+			c.App.Log.Debug("Client disconnected before response: %v", err)
+		}
+		return false
+	}
+	if c.App != nil {
+		c.App.Log.Error("%s waiting for %s %s", err, c.Req.Method, c.Req.URL.Path)
+	}
+	if c.streaming {
+		return false
+	}
+	c.Res.Headers = Headers{}
+	c.Res.Code = 504
+	c.Res.Status = "Gateway Timeout"
+	c.Res.Content = NewAsset("")
+	c.rendered = true
+	return true
+}
@@ -0,0 +1,137 @@
+package mojo_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/preaction/mojo.go"
+	"github.com/preaction/mojo.go/testmojo"
+)
+
+func TestContextRenderLater(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Renderer.AddTemplate("user", "Hello, <% .Stash.name %>!")
+	app.Routes.Get("/user").To(func(c *mojo.Context) {
+		c.RenderLater()
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			c.Stash["name"] = "Fry"
+			c.Render("user")
+		}()
+	})
+	app.Routes.Get("/stream").To(func(c *mojo.Context) {
+		c.RenderLater()
+		c.InactivityTimeout(100 * time.Millisecond)
+		go func() {
+			// Writing is activity that resets the inactivity timeout
+			for _, chunk := range []string{"one ", "two ", "three"} {
+				time.Sleep(60 * time.Millisecond)
+				c.WriteChunk([]byte(chunk), nil)
+			}
+			c.Render("")
+		}()
+	})
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/user").StatusIs(200).TextIs("Hello, Fry!")
+	mt.GetOk("/stream").StatusIs(200).BodyIs("one two three")
+}
+
+func TestContextRenderLaterTimeout(t *testing.T) {
+	rendered := make(chan bool)
+	app := mojo.NewApplication()
+	app.Log.Level("fatal")
+	app.Routes.Get("/slow").To(func(c *mojo.Context) {
+		c.RenderLater()
+		c.InactivityTimeout(20 * time.Millisecond)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			c.Render("")
+			rendered <- true
+		}()
+	})
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/slow").StatusIs(504)
+	<-rendered
+	if mt.Context.Res.Code != 504 {
+		t.Errorf("Render after timeout changed the response: %d", mt.Context.Res.Code)
+	}
+}
+
+func TestContextRenderLaterCancel(t *testing.T) {
+	handled := make(chan time.Duration, 1)
+	app := mojo.NewApplication()
+	app.Log.Level("fatal")
+	app.Routes.Get("/slow").To(func(c *mojo.Context) {
+		c.RenderLater()
+		go func() {
+			select {
+			case <-c.Req.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			c.Render("")
+		}()
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		app.ServeHTTP(w, r)
+		handled <- time.Since(start)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/slow", nil)
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Errorf("Request did not time out")
+	}
+
+	select {
+	case elapsed := <-handled:
+		if elapsed > time.Second {
+			t.Errorf("Handler waited %s after the client disconnected", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Handler did not return after the client disconnected")
+	}
+}
+
+func TestContextRenderLaterAfterDispatch(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/user").To(func(c *mojo.Context) {
+		c.RenderLater()
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			c.Res.Text("Hello, Fry!")
+			c.Render("")
+		}()
+	})
+	seen := ""
+	app.Hook(mojo.AfterDispatch, func(c *mojo.Context) {
+		seen = c.Res.Content.String()
+		c.Res.Headers.Add("X-Seen", seen)
+	})
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/user").StatusIs(200).TextIs("Hello, Fry!")
+	if seen != "Hello, Fry!" {
+		t.Errorf("AfterDispatch hook did not see delayed response. Got: %q", seen)
+	}
+	if got := mt.Context.Res.Headers.Header("X-Seen"); got != "Hello, Fry!" {
+		t.Errorf("AfterDispatch hook could not change the response. Got: %q", got)
+	}
+
+	// Mounted applications wait too
+	parent := mojo.NewApplication()
+	parent.Routes.Mount("/app", app)
+	seen = ""
+	mt = testmojo.NewTester(t, parent)
+	mt.GetOk("/app/user").StatusIs(200).TextIs("Hello, Fry!")
+	if seen != "Hello, Fry!" {
+		t.Errorf("Mounted AfterDispatch hook did not see delayed response. Got: %q", seen)
+	}
+}
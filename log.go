@@ -102,7 +102,11 @@ func (log *Log) message(level string, msg string, args ...interface{}) {
 	log.Write([]byte(fmt.Sprintf(format, args...)))
 }
 
-// Write implements the Writer interface to allow for child Log objects
+// Write implements the Writer interface to allow for child Log objects.
+// Writes to os.Stderr if no Handle is set.
 func (log *Log) Write(msg []byte) (int, error) {
+	if log.Handle == nil {
+		return os.Stderr.Write(msg)
+	}
 	return log.Handle.Write(msg)
 }
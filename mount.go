@@ -0,0 +1,56 @@
+package mojo
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Mount embeds another Application under the given prefix. Requests for
+// paths under the prefix are dispatched to the embedded application's
+// Static and Routes dispatchers (and hooks) with the prefix removed from
+// the "path" stash value. Paths created with Context.URLFor inside the
+// embedded application include the prefix.
+//
+// The prefix can start with a host name to mount the application for
+// a virtual host, like "example.com" or "example.com/app". Host names
+// starting with "*." also match any subdomain, like "*.example.com".
+func (rs *Routes) Mount(prefix string, app *Application) *Route {
+	host := ""
+	if !strings.HasPrefix(prefix, "/") {
+		if slash := strings.IndexByte(prefix, '/'); slash >= 0 {
+			host, prefix = prefix[:slash], prefix[slash:]
+		} else {
+			host, prefix = prefix, ""
+		}
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	r := rs.Any(nil, prefix+"/*mountpath", Stash{"mountpath": "", "format": false})
+	if strings.HasPrefix(host, "*.") {
+		pattern := `^(?:.*\.)?` + regexp.QuoteMeta(strings.ToLower(host[2:])) + `$`
+		r.Requires("host", regexp.MustCompile(pattern))
+	} else if host != "" {
+		r.Requires("host", host)
+	}
+
+	r.To(func(c *Context) {
+		rest := "/" + c.Stash["mountpath"].(string)
+		path := c.Stash["path"].(string)
+		sub := app.BuildContext(c.Req, c.Res)
+		sub.Stash["path"] = rest
+		sub.basePath = c.basePath + strings.TrimSuffix(strings.TrimSuffix(path, rest), "/")
+
+		app.dispatch(sub)
+		if err := sub.wait(); err == nil || sub.timeout(err) {
+			app.emit(AfterDispatch, sub)
+		}
+		if !sub.rendered {
+			sub.Render("")
+		}
+		// The mounted application has its own session cookie
+		sub.storeSession()
+		c.rendered = true
+		c.streaming = sub.streaming
+	})
+	return r
+}
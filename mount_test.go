@@ -0,0 +1,73 @@
+package mojo_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/preaction/mojo.go"
+	"github.com/preaction/mojo.go/testmojo"
+)
+
+func TestRoutesMount(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "*")
+	if err != nil {
+		panic(fmt.Sprintf("Could not make tempdir: %v", err))
+	}
+	err = os.WriteFile(tmp+"/hello.txt", []byte("Hello, Gophers!"), 0600)
+	if err != nil {
+		panic(fmt.Sprintf("Could not write file to tempdir: %v", err))
+	}
+
+	blog := mojo.NewApplication()
+	blog.Static.AddPath(mojo.NewFile(tmp))
+	blog.Routes.Get("/").To(func(c *mojo.Context) { c.Res.Text("Blog index") })
+	blog.Routes.Get("/:id").Named("post").To(func(c *mojo.Context) {
+		c.Res.Text(c.URLFor("post", mojo.Stash{"id": c.Stash["id"]}))
+	})
+
+	app := mojo.NewApplication()
+	app.Routes.Get("/").To(func(c *mojo.Context) { c.Res.Text("Main index") })
+	app.Routes.Mount("/blog", blog)
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/").StatusIs(200).TextIs("Main index")
+	mt.GetOk("/blog").StatusIs(200).TextIs("Blog index")
+	mt.GetOk("/blog/").StatusIs(200).TextIs("Blog index")
+	mt.GetOk("/blog/42").StatusIs(200).TextIs("/blog/42", "URLFor includes mount prefix")
+	mt.GetOk("/blog/hello.txt").StatusIs(200).TextIs("Hello, Gophers!", "Static files are served")
+	mt.GetOk("/blog/1/2").StatusIs(404)
+	mt.GetOk("/blogger").StatusIs(404)
+	mt.GetOk("/hello.txt").StatusIs(404)
+}
+
+func TestRoutesMountHost(t *testing.T) {
+	sub := mojo.NewApplication()
+	sub.Routes.Get("/").To(func(c *mojo.Context) { c.Res.Text("Sub") })
+
+	wild := mojo.NewApplication()
+	wild.Routes.Get("/").To(func(c *mojo.Context) { c.Res.Text("Wild") })
+
+	app := mojo.NewApplication()
+	app.Routes.Mount("example.com/app", sub)
+	app.Routes.Mount("*.example.org", wild)
+	app.Routes.Get("/*any", mojo.Stash{"any": ""}).To(func(c *mojo.Context) { c.Res.Text("Main") })
+
+	tests := []struct{ host, path, expect string }{
+		{"example.com", "/app", "Sub"},
+		{"example.com", "/", "Main"},
+		{"example.net", "/app", "Main"},
+		{"example.org", "/", "Wild"},
+		{"www.example.org", "/", "Wild"},
+		{"example.org.net", "/", "Main"},
+	}
+	for _, tt := range tests {
+		c := app.BuildContext(mojo.NewRequest("GET", tt.path), mojo.NewResponse(httptest.NewRecorder()))
+		c.Req.Headers.Add("Host", tt.host)
+		app.Handler(c)
+		if got := c.Res.Content.String(); got != tt.expect {
+			t.Errorf("%s%s: Got: %q; Expect: %q", tt.host, tt.path, got, tt.expect)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package mojo
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PathNormalization is a set of flags that control how request paths are
+// normalized before routing. See: Application.PathNormalization
+//
+// Paths are normalized before they are percent-decoded, so encoded
+// slashes ("%2F") do not separate segments during normalization. Encoded
+// dots ("%2E") are decoded first, so "/%2E%2E/" is a dot segment like
+// "/../" (see RFC 3986, section 6.2.2.2). Routes match the decoded path,
+// so an encoded slash is a path separator for routing: "/f/a%2Fb" is
+// routed like "/f/a/b", matching "/f/*name" (with a name of "a/b") but
+// not "/f/#name".
+type PathNormalization uint
+
+const (
+	// CollapseSlashes replaces duplicate slashes with a single slash, so
+	// "/users//1" becomes "/users/1"
+	CollapseSlashes PathNormalization = 1 << iota
+	// ResolveDotSegments removes "." segments and resolves ".."
+	// segments, so "/users/./1/../2" becomes "/users/2". ".." segments
+	// never go above the root.
+	ResolveDotSegments
+
+	// DefaultPathNormalization is the normalization used by
+	// NewApplication
+	DefaultPathNormalization = CollapseSlashes | ResolveDotSegments
+)
+
+// SlashPolicy decides how Routes handle requests for paths that only
+// match a route with (or without) a trailing slash. See:
+// Routes.TrailingSlash
+type SlashPolicy int
+
+const (
+	// StrictSlash only matches routes for the exact path. This is the
+	// default.
+	StrictSlash SlashPolicy = iota
+	// MatchSlash matches routes for the path with or without a trailing
+	// slash
+	MatchSlash
+	// RedirectSlash redirects to the path with or without a trailing
+	// slash that matches a route. It also redirects requests for paths
+	// that were changed by normalization to the normalized path.
+	// Redirects use 301 Moved Permanently for GET and HEAD requests, and
+	// 308 Permanent Redirect for other methods.
+	RedirectSlash
+)
+
+// normalizePath normalizes the given escaped path with the given flags.
+// Paths that do not start with "/" are returned as-is.
+func normalizePath(path string, flags PathNormalization) string {
+	if !strings.HasPrefix(path, "/") || flags == 0 {
+		return path
+	}
+	segments := strings.Split(path[1:], "/")
+	normal := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		dot := ""
+		if flags&ResolveDotSegments != 0 {
+			dot = dotSegment(segment)
+		}
+		switch {
+		case segment == "" && !last && flags&CollapseSlashes != 0:
+			continue
+		case dot != "":
+			if dot == ".." && len(normal) > 0 {
+				normal = normal[:len(normal)-1]
+			}
+			// A dot segment at the end leaves a trailing slash
+			if last {
+				normal = append(normal, "")
+			}
+			continue
+		}
+		normal = append(normal, segment)
+	}
+	return "/" + strings.Join(normal, "/")
+}
+
+// decodeDots decodes percent-encoded dots, which are unreserved
+// characters that must not be encoded
+var decodeDots = strings.NewReplacer("%2E", ".", "%2e", ".")
+
+// dotSegment returns "." or ".." if the given escaped segment is a dot
+// segment, or "" if it is not
+func dotSegment(segment string) string {
+	if segment = decodeDots.Replace(segment); segment == "." || segment == ".." {
+		return segment
+	}
+	return ""
+}
+
+// toggleSlash adds a trailing slash to the given path, or removes it if
+// the path already has one
+func toggleSlash(path string) string {
+	if path == "/" || path == "" {
+		return path
+	}
+	if strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path + "/"
+}
+
+// escapePath percent-encodes the given path for use in a URL
+func escapePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
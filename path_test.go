@@ -0,0 +1,114 @@
+package mojo_test
+
+import (
+	"testing"
+
+	"github.com/preaction/mojo.go"
+	"github.com/preaction/mojo.go/testmojo"
+)
+
+func TestApplicationPathNormalization(t *testing.T) {
+	tests := map[string]string{
+		"/users/1":          "/users/1",
+		"//users///1":       "/users/1",
+		"/users/./1":        "/users/1",
+		"/users/2/../1":     "/users/1",
+		"/../../users":      "/users",
+		"/users/1/.":        "/users/1/",
+		"/users/":           "/users/",
+		"/a%20b":            "/a b",
+		"/users/%2E%2E/1":   "/1",
+		"/users/%2e/1":      "/users/1",
+		"/users/.%2E/1":     "/1",
+		"/users/%2E%2Ex/1":  "/users/..x/1",
+		"/users/a%2Fb//../": "/users/",
+	}
+	app := mojo.NewApplication()
+	for path, expect := range tests {
+		c := app.BuildContext(mojo.NewRequest("GET", path), nil)
+		if got := c.Stash["path"]; got != expect {
+			t.Errorf("%s: Got: %q; Expect: %q", path, got, expect)
+		}
+	}
+
+	app.PathNormalization = mojo.ResolveDotSegments
+	c := app.BuildContext(mojo.NewRequest("GET", "//users/./1"), nil)
+	if got := c.Stash["path"]; got != "//users/1" {
+		t.Errorf("Only resolve dot segments: Got: %q; Expect: %q", got, "//users/1")
+	}
+}
+
+func TestApplicationPathEncodedSlash(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/f/#name").To(func(c *mojo.Context) { c.Res.Text("relaxed " + c.Param("name")) })
+	app.Routes.Get("/g/*name").To(func(c *mojo.Context) { c.Res.Text("wildcard " + c.Param("name")) })
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/f/a%2Fb").StatusIs(404)
+	mt.GetOk("/g/a%2Fb").StatusIs(200).TextIs("wildcard a/b")
+	mt.GetOk("/f/a%2Eb").StatusIs(200).TextIs("relaxed a.b")
+}
+
+func TestApplicationPathEncodedDots(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/files/*p").To(func(c *mojo.Context) { c.Res.Text("file " + c.Param("p")) })
+	app.Routes.Get("/x/*p").To(func(c *mojo.Context) { c.Res.Text("x " + c.Param("p")) })
+	app.Routes.Get("/admin/secret").To(func(c *mojo.Context) { c.Res.Text("secret") })
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/files/%2E%2E/%2E%2E/etc/passwd").StatusIs(404)
+	mt.GetOk("/x/%2e%2e/admin/secret").StatusIs(200).TextIs("secret")
+	mt.GetOk("/files/a/%2E%2E/b").StatusIs(200).TextIs("file b")
+}
+
+func TestRoutesTrailingSlash(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Any(nil, "/users").To(func(c *mojo.Context) { c.Res.Text("users") })
+	app.Routes.Any(nil, "/about/").To(func(c *mojo.Context) { c.Res.Text("about") })
+
+	t.Run("StrictSlash", func(t *testing.T) {
+		mt := testmojo.NewTester(t, app)
+		mt.GetOk("/users").StatusIs(200).TextIs("users")
+		mt.GetOk("/users/").StatusIs(404)
+		mt.GetOk("/about").StatusIs(404)
+		mt.GetOk("//users").StatusIs(200).TextIs("users")
+	})
+
+	t.Run("MatchSlash", func(t *testing.T) {
+		app.Routes.TrailingSlash = mojo.MatchSlash
+		defer func() { app.Routes.TrailingSlash = mojo.StrictSlash }()
+		mt := testmojo.NewTester(t, app)
+		mt.GetOk("/users").StatusIs(200).TextIs("users")
+		mt.GetOk("/users/").StatusIs(200).TextIs("users")
+		mt.GetOk("/about").StatusIs(200).TextIs("about")
+		mt.GetOk("/missing/").StatusIs(404)
+	})
+
+	t.Run("RedirectSlash", func(t *testing.T) {
+		app.Routes.TrailingSlash = mojo.RedirectSlash
+		defer func() { app.Routes.TrailingSlash = mojo.StrictSlash }()
+		tests := []struct {
+			method, path string
+			code         int
+			location     string
+		}{
+			{"GET", "/users", 200, ""},
+			{"GET", "/users/", 301, "/users"},
+			{"GET", "/about?q=1", 301, "/about/?q=1"},
+			{"POST", "/about", 308, "/about/"},
+			{"GET", "//users", 301, "/users"},
+			{"GET", "/x/../users", 301, "/users"},
+			{"GET", "/missing/", 404, ""},
+		}
+		for _, tt := range tests {
+			c := app.BuildContext(mojo.NewRequest(tt.method, tt.path), nil)
+			app.Handler(c)
+			if c.Res.Code != tt.code {
+				t.Errorf("%s %s: Status: %d; Expect: %d", tt.method, tt.path, c.Res.Code, tt.code)
+			}
+			if location := c.Res.Headers.Header("Location"); location != tt.location {
+				t.Errorf("%s %s: Location: %q; Expect: %q", tt.method, tt.path, location, tt.location)
+			}
+		}
+	})
+}
@@ -7,10 +7,15 @@ import (
 	"io/fs"
 	"os"
 	"strings"
+	"sync"
 )
 
 // Renderer is an interface for template renderers. Implement this
-// interface to integrate with another template system.
+// interface to integrate with another template system. Renderers that
+// also have a "HasTemplate(name string) bool" method can have
+// mode-specific and format-specific not_found and exception templates.
+// Other renderers always render "not_found.html.tmpl" or
+// "exception.html.tmpl".
 type Renderer interface {
 	AddTemplate(name string, content string)
 	AddHelper(name string, f interface{})
@@ -18,13 +23,48 @@ type Renderer interface {
 	Render(name string, c *Context) string
 }
 
+// templateChecker is a Renderer that can tell if a template exists
+type templateChecker interface {
+	HasTemplate(name string) bool
+}
+
 // GoRenderer implements the Renderer interface using Go's built-in HTML
 // Template system, changing the delimiters from "{{ ... }}" to "<% ...
 // %>".
+//
+// Templates read from the template paths are cached, except when the
+// application is in "development" mode.
 type GoRenderer struct {
 	fs        []fs.FS
 	helpers   map[string]interface{}
 	templates map[string]string
+	cache     map[string]string
+	cacheLock sync.RWMutex
+}
+
+// defaultHelpers are the template functions available in every
+// GoRenderer template.
+var defaultHelpers = map[string]interface{}{
+	"url_for": urlForHelper,
+}
+
+// urlForHelper is the "url_for" template function. It takes the current
+// context, a route name, and then pairs of placeholder names and values:
+//
+//	<a href="<% url_for . "user" "id" .Stash.id %>">Profile</a>
+func urlForHelper(c *Context, name string, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("url_for: odd number of placeholder arguments")
+	}
+	values := Stash{}
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("url_for: placeholder name %v is not a string", pairs[i])
+		}
+		values[key] = pairs[i+1]
+	}
+	return c.URLFor(name, values), nil
 }
 
 // AddHelper adds a template function with the given name.
@@ -61,27 +101,66 @@ func (ren *GoRenderer) AddFS(f fs.FS) {
 	ren.fs = append([]fs.FS{f}, ren.fs...)
 }
 
-// Render renders the named template using the data in the given
-// context.
-func (ren *GoRenderer) Render(name string, c *Context) string {
+// lookup finds the content of the named template in the cache, the
+// template paths, or the built-in templates. Templates read from the
+// template paths are added to the cache if cache is true.
+func (ren *GoRenderer) lookup(name string, cache bool) (string, bool) {
 	// Look up content in the cache
-	content, ok := ren.templates[name]
+	if content, ok := ren.templates[name]; ok {
+		return content, true
+	}
+	if cache {
+		ren.cacheLock.RLock()
+		content, ok := ren.cache[name]
+		ren.cacheLock.RUnlock()
+		if ok {
+			return content, true
+		}
+	}
 	// If missing, look up template from the available filesystems
-	if !ok {
-		for _, f := range ren.fs {
-			bytes, err := fs.ReadFile(f, name)
-			if err != nil && !errors.Is(err, fs.ErrNotExist) {
-				panic(fmt.Sprintf("Could not read template file: %s", err))
-			} else if err != nil {
-				continue
+	for _, f := range ren.fs {
+		bytes, err := fs.ReadFile(f, name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			panic(fmt.Sprintf("Could not read template file: %s", err))
+		} else if err != nil {
+			continue
+		}
+		content := string(bytes)
+		if cache {
+			ren.cacheLock.Lock()
+			if ren.cache == nil {
+				ren.cache = map[string]string{}
 			}
-			content = string(bytes)
-			ren.AddTemplate(name, content)
-			break
+			ren.cache[name] = content
+			ren.cacheLock.Unlock()
 		}
+		return content, true
+	}
+	// If still missing, use a built-in template
+	content, ok := defaultTemplates[name]
+	return content, ok
+}
+
+// HasTemplate returns true if the named template exists in the cache,
+// the template paths, or the built-in templates.
+func (ren *GoRenderer) HasTemplate(name string) bool {
+	ren.cacheLock.RLock()
+	_, ok := ren.cache[name]
+	ren.cacheLock.RUnlock()
+	if ok {
+		return true
 	}
+	_, ok = ren.lookup(name, false)
+	return ok
+}
+
+// Render renders the named template using the data in the given
+// context.
+func (ren *GoRenderer) Render(name string, c *Context) string {
+	cache := c == nil || c.App == nil || c.App.Mode != "development"
+	content, _ := ren.lookup(name, cache)
 
-	t := ren.template(name).Funcs(ren.helpers)
+	t := ren.template(name).Funcs(defaultHelpers).Funcs(ren.helpers)
 	template.Must(t.Parse(content))
 
 	str := strings.Builder{}
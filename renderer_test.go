@@ -2,6 +2,7 @@ package mojo_test
 
 import (
 	"fmt"
+	"io/fs"
 	"testing"
 	"testing/fstest"
 
@@ -74,3 +75,51 @@ func TestGoRendererFSOrder(t *testing.T) {
 		t.Errorf(`Render("bar.html.gt") failed. Expect: "Goodbye!"; Got: %v`, out)
 	}
 }
+
+func TestGoRendererURLFor(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/user/:id").Named("user")
+	app.Renderer.AddTemplate("link", `<a href="<% url_for . "user" "id" .Stash.id %>">Profile</a>`)
+
+	c := app.BuildContext(mojo.NewRequest("GET", "/"), nil)
+	out := c.RenderToString("link", mojo.Stash{"id": "fry"})
+	if out != `<a href="/user/fry">Profile</a>` {
+		t.Errorf(`url_for helper failed. Expect: <a href="/user/fry">Profile</a>; Got: %v`, out)
+	}
+}
+
+func TestGoRendererHasTemplate(t *testing.T) {
+	r := mojo.GoRenderer{}
+	r.AddTemplate("foo", "bar")
+	r.AddFS(fstest.MapFS{"baz.html.tmpl": &fstest.MapFile{Data: []byte("Baz")}})
+	for name, expect := range map[string]bool{
+		"foo":                 true,
+		"baz.html.tmpl":       true,
+		"exception.html.tmpl": true,
+		"missing.html.tmpl":   false,
+	} {
+		if got := r.HasTemplate(name); got != expect {
+			t.Errorf("HasTemplate(%q) = %v; Expect: %v", name, got, expect)
+		}
+	}
+}
+
+// plainRenderer is a Renderer without a HasTemplate method
+type plainRenderer struct{}
+
+func (plainRenderer) AddTemplate(name string, content string) {}
+func (plainRenderer) AddHelper(name string, f interface{})    {}
+func (plainRenderer) AddFS(fs fs.FS)                          {}
+func (plainRenderer) Render(name string, c *mojo.Context) string {
+	return "rendered " + name
+}
+
+func TestRendererWithoutHasTemplate(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Renderer = plainRenderer{}
+	app.Routes.Get("/missing").To(func(c *mojo.Context) { c.NotFound() })
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/missing").StatusIs(404).TextIs("rendered not_found.html.tmpl")
+	mt.GetOk("/missing.json").StatusIs(404).TextIs("rendered not_found.html.tmpl")
+}
@@ -1,6 +1,7 @@
 package mojo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -56,6 +57,16 @@ func (req *Request) Read(raw *http.Request) {
 	req.Headers["Host"] = []string{raw.Host}
 }
 
+// Context returns the request's context.Context, which is cancelled
+// when the client disconnects. Requests that were not read from an
+// http.Request never get cancelled.
+func (req *Request) Context() context.Context {
+	if req.raw == nil {
+		return context.Background()
+	}
+	return req.raw.Context()
+}
+
 // Param gets the first value for the given parameter. Body parameters (POST
 // forms) take precedence over query parameters (URLs). To get every
 // value, see EveryParam.
@@ -69,6 +80,17 @@ func (req *Request) EveryParam(name string) []string {
 	return req.Params.EveryParam(name)
 }
 
+// Cookie returns the cookie with the given name, or nil if the request
+// does not have one.
+func (req *Request) Cookie(name string) *http.Cookie {
+	raw := &http.Request{Header: http.Header(req.Headers)}
+	cookie, err := raw.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	return cookie
+}
+
 // readBody reads the request body if necessary, caches it in the
 // Request object, and returns it.
 func (req *Request) readContent() Asset {
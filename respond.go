@@ -0,0 +1,94 @@
+package mojo
+
+import "sort"
+
+// accepts returns the formats the client accepts, in order of
+// preference, from the "format" stash value, the "format" query
+// parameter, or the Accept header. Returns nil if the client has no
+// preference. "*/*" in the Accept header becomes the "any" format.
+func (c *Context) accepts() []string {
+	if format, ok := c.Stash["format"].(string); ok && format != "" {
+		return []string{format}
+	}
+	if c.Req != nil && c.Req.URL != nil {
+		if format := c.Req.URL.Query().Get("format"); format != "" {
+			return []string{format}
+		}
+	}
+	if c.Req == nil || c.Req.Headers.Header("Accept") == "" {
+		return nil
+	}
+
+	return c.types().detect(c.Req.Headers.Header("Accept"), "any")
+}
+
+// RespondTo calls the handler for the format the client prefers, and
+// puts the format in the "format" stash value:
+//
+//	c.RespondTo(map[string]mojo.Handler{
+//		"json": func(c *mojo.Context) { c.Res.JSON(user) },
+//		"html": func(c *mojo.Context) { c.Render("user.html.tmpl") },
+//		"any":  func(c *mojo.Context) { c.Res.Text(user.Name) },
+//	})
+//
+// The format comes from the "format" stash value (like from a file
+// extension on the route), then the "format" query parameter, then the
+// Accept header (using Types.Detect to find formats for MIME types, in
+// order of preference). Clients with no preference get "html".
+// Clients that accept "*/*" get the "any" handler, the "html" handler,
+// or the first handler in sorted order.
+//
+// If no handler matches, the "any" handler is called. If there is no
+// "any" handler, the response is 406 Not Acceptable if the client asked
+// for a format, or 204 No Content if it did not.
+func (c *Context) RespondTo(handlers map[string]Handler) {
+	formats := c.accepts()
+	preference := formats != nil
+	if !preference {
+		formats = []string{"html"}
+	}
+	for _, format := range formats {
+		// Clients that accept anything get "html" or the first format
+		// (in sorted order) if there is no "any" handler
+		if _, ok := handlers[format]; !ok && format == "any" {
+			format = anyFormat(handlers)
+		}
+		if handler, ok := handlers[format]; ok {
+			if format != "any" {
+				c.Stash["format"] = format
+			}
+			handler(c)
+			return
+		}
+	}
+	if handler, ok := handlers["any"]; ok {
+		handler(c)
+		return
+	}
+
+	if preference {
+		c.Res.Code = 406
+		c.Res.Status = "Not Acceptable"
+	} else {
+		c.Res.Code = 204
+		c.Res.Status = "No Content"
+	}
+	c.Render("")
+}
+
+// anyFormat returns "html" if there is a handler for it, or the first
+// format in sorted order
+func anyFormat(handlers map[string]Handler) string {
+	if _, ok := handlers["html"]; ok {
+		return "html"
+	}
+	formats := make([]string, 0, len(handlers))
+	for format := range handlers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	if len(formats) == 0 {
+		return ""
+	}
+	return formats[0]
+}
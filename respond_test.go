@@ -0,0 +1,73 @@
+package mojo_test
+
+import (
+	"testing"
+
+	"github.com/preaction/mojo.go"
+)
+
+func TestContextRespondTo(t *testing.T) {
+	app := mojo.NewApplication()
+	respond := func(c *mojo.Context) {
+		c.RespondTo(map[string]mojo.Handler{
+			"json": func(c *mojo.Context) { c.Res.Text("json") },
+			"html": func(c *mojo.Context) { c.Res.Text("html") },
+			"txt":  func(c *mojo.Context) { c.Res.Text("txt") },
+		})
+	}
+	app.Routes.Get("/user").To(respond)
+	app.Routes.Get("/any").To(func(c *mojo.Context) {
+		c.RespondTo(map[string]mojo.Handler{
+			"json": func(c *mojo.Context) { c.Res.Text("json") },
+			"any":  func(c *mojo.Context) { c.Res.Text("any") },
+		})
+	})
+	app.Routes.Get("/json").To(func(c *mojo.Context) {
+		c.RespondTo(map[string]mojo.Handler{
+			"json": func(c *mojo.Context) { c.Res.Text("json") },
+		})
+	})
+
+	tests := []struct {
+		path   string
+		accept string
+		code   int
+		body   string
+		format interface{}
+	}{
+		{"/user", "", 200, "html", "html"},
+		{"/user.json", "", 200, "json", "json"},
+		{"/user.txt", "application/json", 200, "txt", "txt"},
+		{"/user?format=json", "text/html", 200, "json", "json"},
+		{"/user", "application/json", 200, "json", "json"},
+		{"/user", "text/plain;q=0.5, application/json;q=0.9", 200, "json", "json"},
+		{"/user", "application/json;q=0, text/plain", 200, "txt", "txt"},
+		{"/user", "image/png, */*;q=0.1", 200, "html", "html"},
+		{"/user", "image/png", 406, "", nil},
+		{"/user.png", "", 406, "", "png"},
+		{"/any", "image/png", 200, "any", nil},
+		{"/any", "", 200, "any", nil},
+		{"/any", "*/*, application/json;q=0.5", 200, "any", nil},
+		{"/any", "application/json, */*;q=0.5", 200, "json", "json"},
+		{"/json", "", 204, "", nil},
+		{"/json", "*/*", 200, "json", "json"},
+		{"/json", "text/*", 406, "", nil},
+	}
+	for _, tt := range tests {
+		c := app.BuildContext(mojo.NewRequest("GET", tt.path), nil)
+		if tt.accept != "" {
+			c.Req.Headers.Add("Accept", tt.accept)
+		}
+		app.Handler(c)
+		name := tt.path + " " + tt.accept
+		if c.Res.Code != tt.code {
+			t.Errorf("%s: Status: %d; Expect: %d", name, c.Res.Code, tt.code)
+		}
+		if got := c.Res.Content.String(); got != tt.body {
+			t.Errorf("%s: Body: %q; Expect: %q", name, got, tt.body)
+		}
+		if got := c.Stash["format"]; got != tt.format {
+			t.Errorf("%s: Format: %v; Expect: %v", name, got, tt.format)
+		}
+	}
+}
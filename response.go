@@ -37,19 +37,40 @@ func (res *Response) Read(raw *http.Response) {
 }
 
 // JSON encodes the given argument as JSON and updates the response's
-// Content-Type header.
+// Content-Type header to the "json" type in DefaultTypes, without its
+// parameters ("application/json").
 func (res *Response) JSON(data interface{}) {
 	json, err := json.Marshal(data)
 	if err != nil {
 		panic(err)
 	}
 	res.Content = NewAsset(json)
-	res.Headers["Content-Type"] = []string{"application/json"}
+	res.Headers["Content-Type"] = []string{DefaultTypes.mediaType("json")}
 }
 
 // Text sets the response's content and updates the Content-Type header
-// to "text/plain"
+// to the "txt" type in DefaultTypes, without its parameters
+// ("text/plain")
 func (res *Response) Text(str string) {
 	res.Content = NewAsset(str)
-	res.Headers["Content-Type"] = []string{"text/plain"}
+	res.Headers["Content-Type"] = []string{DefaultTypes.mediaType("txt")}
+}
+
+// Redirect sets the response's Location header to the given URL and the
+// status to the given code. Uses a 302 Found status if code is 0.
+func (res *Response) Redirect(location string, code int) {
+	if code == 0 {
+		code = http.StatusFound
+	}
+	res.Code = code
+	res.Status = http.StatusText(code)
+	res.Headers["Location"] = []string{location}
+}
+
+// SetCookie adds a Set-Cookie header for the given cookie. Invalid
+// cookies are ignored.
+func (res *Response) SetCookie(cookie *http.Cookie) {
+	if value := cookie.String(); value != "" {
+		res.Headers.Add("Set-Cookie", value)
+	}
 }
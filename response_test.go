@@ -46,3 +46,14 @@ func TestResponseJSON(t *testing.T) {
 		t.Errorf("JSON() content incorrect; Expect: %s; Got: %s", expect, res.Content)
 	}
 }
+
+func TestResponseRedirect(t *testing.T) {
+	res := mojo.NewResponse()
+	res.Redirect("/new", 0)
+	if res.Code != 302 || res.Status != "Found" {
+		t.Errorf("Redirect() status incorrect; Expect: 302 Found; Got: %d %s", res.Code, res.Status)
+	}
+	if res.Headers.Header("Location") != "/new" {
+		t.Errorf("Redirect() Location incorrect; Expect: /new; Got: %s", res.Headers.Header("Location"))
+	}
+}
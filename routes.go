@@ -2,7 +2,12 @@ package mojo
 
 import (
 	"fmt"
+	"net/url"
 	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/preaction/mojo.go/util"
 )
@@ -10,21 +15,56 @@ import (
 // Routes stores the application routes and handles matching routes to
 // incoming requests
 type Routes struct {
-	routes []*Route
+	routes     []*Route
+	owner      *Route
+	types      map[string]*regexp.Regexp
+	conditions map[string]Condition
+	shortcuts  map[string]Shortcut
+	compiled   bool
+	tree       *routeTree
+	treeLock   sync.Mutex
+
+	// TrailingSlash decides how to handle requests for paths that only
+	// match a route with (or without) a trailing slash. Only the setting
+	// of the top-level Routes is used.
+	TrailingSlash SlashPolicy
 }
 
 // Handler handles an incoming request. Handlers can modify the stash or
 // response for subsequent handlers or render a response body.
 type Handler func(*Context)
 
-// Route is a single endpoint
+// Shortcut is a reusable route builder. Shortcuts are given the Route to
+// add routes to and the arguments passed to Routes.Shortcut, and return
+// the Route they created. See: AddShortcut
+type Shortcut func(*Route, ...interface{}) *Route
+
+// Route is a single endpoint, or a group of nested routes. Routes can
+// be nested by adding routes to an existing Route:
+//
+//	users := app.Routes.Any(nil, "/users")
+//	users.Get("/").To(ListUsers)
+//	users.Get("/:id").To(ShowUser)
+//
+// Nested routes inherit the defaults of the routes they are nested
+// under, and must also allow the request method. Routes with nested
+// routes only match if one of their nested routes matches.
 type Route struct {
 	*Routes
-	Name     string
-	Methods  util.StringSlice
-	Pattern  *regexp.Regexp
-	Defaults Stash
-	Handler  Handler
+	Name         string
+	Methods      util.StringSlice
+	Pattern      *regexp.Regexp
+	Defaults     Stash
+	Handler      Handler
+	parent       *Routes
+	path         string
+	tokens       []patternToken
+	customName   bool
+	format       interface{}
+	prefix       *regexp.Regexp
+	conditions   []routeCondition
+	restrictions Stash
+	middleware   []Handler
 }
 
 // Match is a set of route destinations for a given request
@@ -33,10 +73,53 @@ type Match struct {
 	Position int
 }
 
-var stdPlaceholder *regexp.Regexp
+// placeholderPatterns maps placeholder types to the regular expression
+// used to match their value
+var placeholderPatterns = map[byte]string{
+	':': `[^/.]`, // Standard placeholder
+	'#': `[^/]`,  // Relaxed placeholder
+	'*': `.`,     // Wildcard placeholder
+}
+
+// defaultTypes are the placeholder types available to every Routes
+// object. See: AddType
+var defaultTypes = map[string]*regexp.Regexp{
+	"num": regexp.MustCompile(`[0-9]+`),
+}
+
+// AddType registers a placeholder type with the given name. Placeholders
+// can then be restricted to this type by adding the type name after the
+// placeholder name, like "/user/<id:uuid>". Types must be added before
+// any routes that use them. The pattern must not be anchored with "^" or
+// "$", since it matches inside the route's pattern.
+func (rs *Routes) AddType(name string, pattern *regexp.Regexp) {
+	if isAnchored(pattern) {
+		panic(fmt.Sprintf("Placeholder type %q must not be anchored", name))
+	}
+	if rs.types == nil {
+		rs.types = map[string]*regexp.Regexp{}
+	}
+	rs.types[name] = pattern
+}
+
+// lookupType finds the placeholder type with the given name in these
+// routes or any parent routes. Returns nil if the type is not found.
+func (rs *Routes) lookupType(name string) *regexp.Regexp {
+	for ; rs != nil; rs = rs.parentRoutes() {
+		if pattern, ok := rs.types[name]; ok {
+			return pattern
+		}
+	}
+	return defaultTypes[name]
+}
 
-func init() {
-	stdPlaceholder = regexp.MustCompile("(^|[/<])([:])([a-zA-Z_]+)($|[/>])")
+// parentRoutes returns the Routes containing the Route that owns these
+// routes, or nil if these are the top-level routes.
+func (rs *Routes) parentRoutes() *Routes {
+	if rs.owner == nil {
+		return nil
+	}
+	return rs.owner.parent
 }
 
 // Any creates a Route to handle any of the given methods for the given
@@ -46,28 +129,174 @@ func init() {
 // Standard placeholders begin with ":" and match all characters except
 // for "/" and ".".
 //
+// Relaxed placeholders begin with "#" and match all characters except
+// for "/".
+//
+// Wildcard placeholders begin with "*" and match all characters,
+// including "/".
+//
 // Placeholders can be made optional by providing default values in
 // a Stash as an additional argument. Slashes before optional
 // placeholders also become optional.
 //
 // Placeholders can be enclosed in "<" and ">" to separate them from the
-// surrounding text.
+// surrounding text, like "/hello_<:name>" or "/files/<*path>".
+//
+// Routes detect the format of the response from the file extension at
+// the end of the path and put it in the "format" stash value, so
+// "/report.json" matches "/report" with a "format" of "json". Only the
+// formats in the Application's Types are detected, so "/report.exe" does
+// not match (see: Types.Register). Format detection can be disabled with
+// Stash{"format": false}, or restricted to a list of formats with
+// Stash{"format": []string{"json", "xml"}}.
+// Restricted formats are required unless a default "format" is given
+// in a parent route. Routes inherit format detection settings from the
+// routes they are nested under. Format detection is disabled for routes
+// with a ":format" placeholder.
+//
+// Placeholders can be restricted to a list of values by providing
+// a []string for the placeholder's name in the Stash, like
+// Stash{"format": []string{"json", "xml"}}, or to a regular expression
+// by providing a *regexp.Regexp. Regular expressions must not be anchored
+// with "^" or "$".
+// Placeholders can also be restricted to a type by adding the type name
+// inside the brackets, like "/item/<id:num>". The "num" type is
+// built-in, and more types can be added with AddType. Restricted
+// placeholders match only the given values, including "/" and "."
+// characters if the restriction allows them.
 //
 // If placeholders are not powerful enough, the path can contain named
 // capture groups as a regular expression, like "(?P<name>\d+)" to match
 // only digits.
 func (rs *Routes) Any(methods []string, path string, opts ...interface{}) *Route {
-	stash := optionalStash(opts)
-	pathPattern := parsePattern(path, stash)
+	tokens := tokenizePattern(path)
+	stash, restrictions := splitRestrictions(optionalStash(opts), tokens)
 	r := &Route{
-		Methods:  methods,
-		Pattern:  regexp.MustCompile(pathPattern),
-		Defaults: stash,
+		Name:         defaultName(path),
+		Methods:      methods,
+		Defaults:     stash,
+		parent:       rs,
+		path:         path,
+		tokens:       tokens,
+		restrictions: restrictions,
 	}
+	r.Routes = &Routes{owner: r}
+	r.setFormat(restrictions)
+
+	// Placeholders with defaults in parent routes are also optional
+	pathPattern := rs.parsePattern(path, r.tokens, r.defaults(), restrictions)
+	r.Pattern = regexp.MustCompile("^" + pathPattern + r.formatPattern())
+	r.prefix = regexp.MustCompile("^" + pathPattern)
+
 	rs.routes = append(rs.routes, r)
+	rs.root().resetTree()
 	return r
 }
 
+// root returns the top-level Routes object
+func (rs *Routes) root() *Routes {
+	for rs.parentRoutes() != nil {
+		rs = rs.parentRoutes()
+	}
+	return rs
+}
+
+// defaults returns the default stash values for this route, including
+// the defaults of the routes it is nested under
+func (r *Route) defaults() Stash {
+	defaults := Stash{}
+	for _, cr := range r.chain() {
+		defaults.Merge(cr.Defaults)
+	}
+	return defaults
+}
+
+// setFormat sets the format detection setting for this route from the
+// given restrictions, unless the route has its own "format" placeholder.
+// See: Any
+func (r *Route) setFormat(restrictions Stash) {
+	for _, token := range r.tokens {
+		if token.name == "format" {
+			return
+		}
+	}
+	if format, ok := restrictions["format"]; ok {
+		r.format = format
+	}
+}
+
+// formatDetection returns the regexp for the formats detected from the
+// file extension for this endpoint route (or the empty string if format
+// detection is disabled), and whether a format is required.
+func (r *Route) formatDetection() (string, bool) {
+	for _, token := range r.tokens {
+		if token.name == "format" {
+			return "", false
+		}
+	}
+
+	var format interface{} = true
+	hasDefault := false
+	for _, cr := range r.chain() {
+		if cr.format != nil {
+			format = cr.format
+		}
+		if _, ok := cr.Defaults["format"]; ok {
+			hasDefault = true
+		}
+	}
+
+	if enabled, ok := format.(bool); ok {
+		if !enabled {
+			return "", false
+		}
+		return anyFormatPattern, false
+	}
+	return restrictionPattern(format), !hasDefault
+}
+
+// anyFormatPattern is the format detection pattern for routes that are not
+// restricted to a list of formats
+const anyFormatPattern = `[^/.]+`
+
+// allowsFormat returns true if the given format, detected from the file
+// extension, is allowed for this endpoint route. Routes that are not
+// restricted to a list of formats only detect the formats in the
+// Application's Types.
+func (r *Route) allowsFormat(c *Context, format string) bool {
+	if formats, _ := r.formatDetection(); formats != anyFormatPattern {
+		return true
+	}
+	return c.types().Type(format) != ""
+}
+
+// formatPattern returns the end of the regexp for an endpoint route,
+// including format detection if it is enabled.
+func (r *Route) formatPattern() string {
+	formats, required := r.formatDetection()
+	if formats == "" {
+		return "$"
+	}
+	pattern := fmt.Sprintf(`\.(?P<format>(?:%s))`, formats)
+	if required {
+		return pattern + "$"
+	}
+	return fmt.Sprintf("(?:%s)?$", pattern)
+}
+
+// defaultName returns the automatic name for a route with the given
+// path: The path with all non-word characters removed, so
+// "/user/:id/edit" becomes "useridedit".
+func defaultName(path string) string {
+	name := ""
+	for i := 0; i < len(path); i++ {
+		if isPlaceholderName(path[i]) {
+			name += string(path[i])
+		}
+	}
+	return strings.ToLower(name)
+}
+
 // optionalStash finds and returns the first Stash object in the given
 // array of options to a function. If no Stash object is found, returns
 // an empty Stash object.
@@ -83,49 +312,241 @@ func optionalStash(opts []interface{}) Stash {
 	return Stash{}
 }
 
+// splitRestrictions separates placeholder restrictions ([]string and
+// *regexp.Regexp values for the placeholders in the given tokens, or
+// "format") and format detection settings from the default values in
+// the given Stash.
+func splitRestrictions(stash Stash, tokens []patternToken) (Stash, Stash) {
+	placeholders := map[string]bool{"format": true}
+	for _, token := range tokens {
+		if token.name != "" {
+			placeholders[token.name] = true
+		}
+	}
+	defaults := Stash{}
+	restrictions := Stash{}
+	for k, v := range stash {
+		switch v.(type) {
+		case []string, *regexp.Regexp:
+			if !placeholders[k] {
+				defaults[k] = v
+				continue
+			}
+			if pattern, ok := v.(*regexp.Regexp); ok && isAnchored(pattern) {
+				panic(fmt.Sprintf("Restriction for placeholder %q must not be anchored", k))
+			}
+			restrictions[k] = v
+		case bool:
+			// Format detection can be enabled or disabled
+			if k == "format" {
+				restrictions[k] = v
+				continue
+			}
+			defaults[k] = v
+		default:
+			defaults[k] = v
+		}
+	}
+	return defaults, restrictions
+}
+
+// patternToken is a single part of a route pattern: Either literal text
+// (which may contain regular expression syntax) or a placeholder.
+type patternToken struct {
+	text  string // The literal text, if this is not a placeholder
+	slash bool   // true if the placeholder follows a "/"
+	kind  byte   // The placeholder kind: ':', '#', or '*'
+	name  string // The placeholder name
+	typ   string // The placeholder type, if any. See: AddType
+}
+
+// isPlaceholderName returns true if the given character can be part of
+// a placeholder name
+func isPlaceholderName(char byte) bool {
+	return char == '_' || ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z') || ('0' <= char && char <= '9')
+}
+
+// tokenizePattern splits the given path into literal text and
+// placeholder tokens. Placeholders must either begin a path segment
+// or be enclosed in "<" and ">".
+func tokenizePattern(path string) []patternToken {
+	tokens := []patternToken{}
+	literal := ""
+	addPlaceholder := func(token patternToken) {
+		token.slash = strings.HasSuffix(literal, "/")
+		if token.slash {
+			literal = literal[:len(literal)-1]
+		}
+		if literal != "" {
+			tokens = append(tokens, patternToken{text: literal})
+			literal = ""
+		}
+		tokens = append(tokens, token)
+	}
+
+	for i := 0; i < len(path); i++ {
+		char := path[i]
+
+		// Bracketed placeholder: "<:name>", "<#name>", "<*name>", or
+		// with a type, "<name:type>"
+		if char == '<' {
+			if end := strings.IndexByte(path[i:], '>'); end > 0 {
+				if token, ok := parseBracketPlaceholder(path[i+1 : i+end]); ok {
+					addPlaceholder(token)
+					i += end
+					continue
+				}
+			}
+		}
+
+		// Bare placeholder at the start of a path segment
+		if placeholderPatterns[char] != "" && (i == 0 || path[i-1] == '/') {
+			end := i + 1
+			for end < len(path) && isPlaceholderName(path[end]) {
+				end++
+			}
+			if end > i+1 {
+				addPlaceholder(patternToken{kind: char, name: path[i+1 : end]})
+				i = end - 1
+				continue
+			}
+		}
+
+		literal += string(char)
+	}
+	if literal != "" {
+		tokens = append(tokens, patternToken{text: literal})
+	}
+	return tokens
+}
+
+// restrictionPattern returns the regular expression for the given
+// placeholder restriction: A list of allowed values or a regular
+// expression.
+func restrictionPattern(restriction interface{}) string {
+	switch v := restriction.(type) {
+	case []string:
+		values := make([]string, len(v))
+		for i, value := range v {
+			values[i] = regexp.QuoteMeta(value)
+		}
+		return strings.Join(values, "|")
+	case *regexp.Regexp:
+		return v.String()
+	}
+	return ""
+}
+
+// isAnchored returns true if the given regular expression has anchors
+// like "^" or "$", which cannot match inside a route's pattern
+func isAnchored(pattern *regexp.Regexp) bool {
+	re, err := syntax.Parse(pattern.String(), syntax.Perl)
+	return err == nil && hasAnchor(re)
+}
+
+// hasAnchor returns true if the given parsed regular expression or any
+// of its parts is an anchor
+func hasAnchor(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasAnchor(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBracketPlaceholder parses the inside of a bracketed placeholder.
+// Returns false if the text is not a placeholder. Brackets without
+// a placeholder type character must have a placeholder type, so that
+// they do not conflict with regular expression named captures.
+func parseBracketPlaceholder(text string) (patternToken, bool) {
+	token := patternToken{kind: ':'}
+	if text != "" && placeholderPatterns[text[0]] != "" {
+		token.kind = text[0]
+		text = text[1:]
+	} else if !strings.Contains(text, ":") {
+		return token, false
+	}
+	if colon := strings.IndexByte(text, ':'); colon >= 0 {
+		token.typ = text[colon+1:]
+		text = text[:colon]
+		if !isPlaceholderWord(token.typ) {
+			return token, false
+		}
+	}
+	token.name = text
+	return token, isPlaceholderWord(token.name)
+}
+
+// isPlaceholderWord returns true if the given string is a valid
+// placeholder or type name
+func isPlaceholderWord(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if !isPlaceholderName(word[i]) {
+			return false
+		}
+	}
+	return word != ""
+}
+
 // parsePattern parses the given path with placeholders and returns
 // a string suitable for a regexp. This string does not contain
 // start/end anchors, so that different route types can choose different
 // anchors.
-func parsePattern(path string, stash Stash) string {
-	// Standard placeholders
-	// XXX: Add relaxed and wildcard placeholders
-	// XXX: Add restricted placeholders
+func (rs *Routes) parsePattern(path string, tokens []patternToken, stash Stash, restrictions Stash) string {
 	pathPattern := ""
-	lastIndex := 0
-	for _, v := range stdPlaceholder.FindAllStringSubmatchIndex(path, -1) {
-		// v is an array of pairs of ints. Each pair is start and end
-		// indexes of the match in the string. The first pair is the
-		// entire match, and other pairs are the corresponding
-		// submatches
-		gap := path[lastIndex:v[0]]
-		lastIndex = v[1]
-
-		start := path[v[2]:v[3]]
-		if start != "/" {
-			start = ""
-		}
-
-		//placeType := path[v[4]:v[5]]
-		placeName := path[v[6]:v[7]]
-		//end := path[v[8]:v[9]] // unused
-
-		matchType := "+" // required
-		if _, ok := stash[placeName]; ok {
-			matchType = "*" // optional
-			if start == "/" {
-				start += "?"
+	for _, token := range tokens {
+		if token.name == "" {
+			pathPattern += token.text
+			continue
+		}
+
+		start := ""
+		if token.slash {
+			start = "/"
+		}
+		_, optional := stash[token.name]
+
+		// Restricted placeholders only match the allowed values
+		restriction := ""
+		if token.typ != "" {
+			typePattern := rs.lookupType(token.typ)
+			if typePattern == nil {
+				panic(fmt.Sprintf("Unknown placeholder type %q in route %q", token.typ, path))
 			}
+			restriction = typePattern.String()
+		}
+		if value, ok := restrictions[token.name]; ok {
+			restriction = restrictionPattern(value)
 		}
 
-		pathPattern += gap + fmt.Sprintf("%s(?P<%s>[^/.]%s)", start, placeName, matchType)
-	}
+		var placeholder string
+		if restriction != "" {
+			matchType := "" // required
+			if optional {
+				matchType = "?" // optional
+			}
+			placeholder = fmt.Sprintf("(?P<%s>(?:%s)%s)", token.name, restriction, matchType)
+		} else {
+			matchType := "+" // required
+			if optional {
+				matchType = "*" // optional
+			}
+			placeholder = fmt.Sprintf("(?P<%s>%s%s)", token.name, placeholderPatterns[token.kind], matchType)
+		}
 
-	// If we never matched, there were no placeholders
-	if pathPattern == "" {
-		return path
+		// The slash before an optional placeholder is optional, but
+		// only along with the placeholder
+		if optional && token.slash {
+			pathPattern += fmt.Sprintf("(?:%s%s)?", start, placeholder)
+			continue
+		}
+		pathPattern += start + placeholder
 	}
-
 	return pathPattern
 }
 
@@ -145,57 +566,210 @@ func (rs *Routes) Delete(pattern string, opts ...interface{}) *Route {
 	return rs.Any([]string{"DELETE"}, pattern, opts...)
 }
 
+// AddShortcut registers a route builder with the given name. Routes can
+// then use the shortcut with Routes.Shortcut:
+//
+//	app.Routes.AddShortcut("resource", func(r *mojo.Route, args ...interface{}) *mojo.Route {
+//		name := args[0].(string)
+//		resource := r.Any(nil, "/"+name)
+//		resource.Get("/").To(...)
+//		resource.Get("/:id").To(...)
+//		return resource
+//	})
+//	app.Routes.Shortcut("resource", "users")
+//
+// Shortcuts are available to these routes and any nested routes.
+func (rs *Routes) AddShortcut(name string, shortcut Shortcut) {
+	if rs.shortcuts == nil {
+		rs.shortcuts = map[string]Shortcut{}
+	}
+	rs.shortcuts[name] = shortcut
+}
+
+// lookupShortcut finds the shortcut with the given name in these routes
+// or any parent routes. Returns nil if the shortcut is not found.
+func (rs *Routes) lookupShortcut(name string) Shortcut {
+	for ; rs != nil; rs = rs.parentRoutes() {
+		if shortcut, ok := rs.shortcuts[name]; ok {
+			return shortcut
+		}
+	}
+	return nil
+}
+
+// Shortcut calls the shortcut with the given name and arguments to add
+// routes to these routes. The shortcut is given the Route that owns
+// these routes, or a Route containing the top-level routes. Returns the
+// Route returned by the shortcut. See: AddShortcut
+func (rs *Routes) Shortcut(name string, args ...interface{}) *Route {
+	shortcut := rs.lookupShortcut(name)
+	if shortcut == nil {
+		panic(fmt.Sprintf("Unknown route shortcut %q", name))
+	}
+	owner := rs.owner
+	if owner == nil {
+		owner = &Route{Routes: rs}
+	}
+	return shortcut(owner, args...)
+}
+
 // Under creates an intermediate destination. Under routes can have
 // further destinations nested inside them. The handler given to Under
 // must return a boolean to determine whether to continue dispatch.
 func (rs *Routes) Under(pattern string, handler func(*Context) bool, opts ...interface{}) *Route {
-	stash := optionalStash(opts)
-	pathPattern := parsePattern(pattern, stash)
-	r := &Route{
-		Methods:  []string{"GET", "POST", "PATCH", "PUT", "DELETE"},
-		Pattern:  regexp.MustCompile(pathPattern),
-		Defaults: stash,
-		Routes:   &Routes{},
-		Handler: func(c *Context) {
-			c.continueDispatch = handler(c)
-		},
+	r := rs.Any(nil, pattern, opts...)
+	r.Handler = func(c *Context) {
+		c.continueDispatch = handler(c)
 	}
-	rs.routes = append(rs.routes, r)
 	return r
 }
 
-// findRoute finds a matching route for the given method and path,
-// returning the route and any placeholder values to be put into the
-// stash
-func (rs *Routes) findRoute(method string, path string) (*Route, *Stash) {
-	for _, r := range rs.routes {
-		// Check method
-		if !r.Methods.Has(method) {
-			continue
+// Redirect creates a route that redirects requests for the from path to
+// the to path with the given status code (302 if code is 0). Placeholders
+// in the to path are filled in from the matched stash values:
+//
+//	app.Routes.Redirect("/blog/:id", "/posts/:id", 301)
+//
+// The to path can also be the name of a route or a full URL.
+func (rs *Routes) Redirect(from string, to string, code int) *Route {
+	tokens := tokenizePattern(to)
+	r := rs.Any(nil, from)
+	r.Handler = func(c *Context) {
+		var location string
+		if c.App != nil && c.App.Routes.Lookup(to) != nil {
+			location = c.URLFor(to, c.Stash)
+		} else {
+			location = interpolate(tokens, c.Stash, nil)
+			if strings.HasPrefix(location, "/") {
+				location = c.basePath + location
+			}
 		}
+		c.Res.Redirect(location, code)
+	}
+	return r
+}
+
+// allowsMethod returns true if the route handles the given method.
+// Routes without any methods handle every method. Routes that handle
+// GET also handle HEAD.
+func (r *Route) allowsMethod(method string) bool {
+	if len(r.Methods) == 0 || r.Methods.Has(method) {
+		return true
+	}
+	return method == "HEAD" && r.Methods.Has("GET")
+}
+
+// allowedMethods returns the methods with routes matching the given
+// path, suitable for the Allow header.
+func (rs *Routes) allowedMethods(c *Context, path string) []string {
+	methods := util.StringSlice{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	rs.walk(func(r *Route) bool {
+		for _, method := range r.Methods {
+			if !methods.Has(method) {
+				methods = append(methods, method)
+			}
+		}
+		return true
+	})
+
+	allowed := util.StringSlice{}
+	for _, method := range methods {
+		if stack, _ := rs.findMatch(c, method, path); stack != nil {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) > 0 && !allowed.Has("OPTIONS") {
+		allowed = append(allowed, "OPTIONS")
+	}
+	sort.Strings(allowed)
+	return allowed
+}
 
-		// Check path
-		// BUG: If no children, must match completely (not prefix)
-		regexpMatch := r.Pattern.FindStringSubmatch(path)
-		if regexpMatch == nil {
+// captures adds the values captured by the given regexp match to the
+// given stash
+func (r *Route) captures(stash Stash, pattern *regexp.Regexp, match []string) {
+	names := pattern.SubexpNames()
+	for i, value := range match {
+		// Unnamed groups (from restrictions or regexp routes) are not
+		// placeholders
+		if i == 0 || value == "" || names[i] == "" {
 			continue
 		}
+		stash[names[i]] = value
+	}
+}
+
+// matchPath matches the given path against the route's pattern.
+// Endpoints must match the entire path. Routes with nested routes must
+// match a prefix ending at a "/" (which is left for the nested routes to
+// match). If the path matches, adds the placeholder values to the given
+// stash and returns the rest of the path.
+func (r *Route) matchPath(path string, stash Stash) (string, bool) {
+	if path == "" {
+		path = "/"
+	}
+	pattern := r.Pattern
+	if len(r.routes) > 0 {
+		pattern = r.prefix
+	}
+	regexpMatch := pattern.FindStringSubmatch(path)
+	if regexpMatch == nil {
+		return "", false
+	}
+	end := len(regexpMatch[0])
+	if len(r.routes) > 0 {
+		if strings.HasSuffix(regexpMatch[0], "/") {
+			end--
+		} else if end < len(path) && path[end] != '/' {
+			return "", false
+		}
+	}
+	r.captures(stash, pattern, regexpMatch)
+	return path[end:], true
+}
 
-		// Add placeholder values to stash
+// match finds the routes matching the given method and path, and whose
+// conditions pass for the given context. Routes with nested routes
+// match a prefix of the path and then try to match the rest of the path
+// with their nested routes. Returns the stack of matched routes, from
+// the top-level route to the endpoint, and the stash values captured
+// by placeholders.
+func (rs *Routes) match(c *Context, method string, path string) ([]*Route, Stash) {
+	for _, r := range rs.routes {
+		if !r.allowsMethod(method) {
+			continue
+		}
 		stash := Stash{}
-		stash.Merge(r.Defaults)
-		stashNames := r.Pattern.SubexpNames()
-		for i, value := range regexpMatch {
-			if i == 0 || value == "" {
+		rest, ok := r.matchPath(path, stash)
+		if !ok || !r.checkConditions(c) {
+			continue
+		}
+		if len(r.routes) == 0 {
+			if format, ok := stash["format"].(string); ok && !r.allowsFormat(c, format) {
 				continue
 			}
-			stash[stashNames[i]] = value
+			return []*Route{r}, stash
 		}
-		return r, &stash
+		stack, childStash := r.Routes.match(c, method, rest)
+		if stack == nil {
+			continue
+		}
+		stash.Merge(childStash)
+		return append([]*Route{r}, stack...), stash
 	}
 	return nil, nil
 }
 
+// findMatch finds the routes matching the given method and path using
+// the compiled route tree, if Compile has been called, or by matching
+// the pattern of every route. See: match
+func (rs *Routes) findMatch(c *Context, method string, path string) ([]*Route, Stash) {
+	if rs.compiled {
+		return rs.routeTree().match(c, method, path)
+	}
+	return rs.match(c, method, path)
+}
+
 // Match tries to find the route(s) for the given Context. If found, the
 // context's Match value will be set to an array of Route objects to
 // call, in order. See: Dispatch
@@ -205,57 +779,84 @@ func (rs *Routes) Match(c *Context) {
 	// XXX: Replace with Log
 	//fmt.Printf("[debug] %s %s\n", method, path)
 
-	r, stash := rs.findRoute(method, path)
-	if r == nil {
+	stack, stash := rs.findMatch(c, method, path)
+	if stack == nil {
 		return
 	}
 
-	// Matched!
+	// Matched! Route defaults are merged before any captured values.
+	// See: Application.Defaults
 	if c.Match == nil {
 		c.Match = &Match{}
 	}
-	c.Match.Append(r)
-
-	// XXX: If there are child routes of this route (Under), try to
-	// append to the match stack
-	for r.Routes != nil && len(r.Routes.routes) > 0 {
-		// Remove the last match off the front
-		childPath := r.Pattern.ReplaceAllString(path, "")
-		// Try to match again
-		cr, cstash := r.findRoute(method, childPath)
-
-		if cr == nil {
-			// If we had a child to match but didn't, matching fails
-			c.Match = nil
-			return
-		}
-		c.Match.Append(cr)
-		stash.Merge(*cstash)
-
-		// Keep trying
-		r = cr
-		path = childPath
+	for _, r := range stack {
+		c.Match.Append(r)
+		c.Stash.Merge(r.Defaults)
 	}
-
-	// Matching succeeded!
-	c.Stash.Merge(*stash)
+	c.Stash.Merge(stash)
 }
 
 // Dispatch takes the given context, finds matching route(s), and calls
-// their handlers.
+// their handlers. Middleware added to a route with Use is called before
+// the route's handler. Handlers and middleware can stop dispatch with
+// Context.Stop.
+//
+// If no route matches the request method, but some routes match the
+// path, the response is a 405 Method Not Allowed with an Allow header
+// listing the methods that would match, rendered with the "not_found"
+// template like Context.NotFound. OPTIONS requests that do not
+// match a route get a 204 No Content response with the same Allow
+// header. HEAD requests are handled by GET routes.
+//
+// Paths that only match a route with (or without) a trailing slash are
+// handled according to the TrailingSlash policy.
 func (rs *Routes) Dispatch(c *Context) {
 	rs.Match(c)
+	if c.Match == nil && rs.TrailingSlash != StrictSlash {
+		path := toggleSlash(c.Stash["path"].(string))
+		if stack, _ := rs.findMatch(c, c.Req.Method, path); stack != nil {
+			if rs.TrailingSlash == RedirectSlash {
+				rs.redirectCanonical(c, c.basePath+escapePath(path))
+				return
+			}
+			c.Stash["path"] = path
+			rs.Match(c)
+		}
+	}
+	if c.Match != nil && rs.TrailingSlash == RedirectSlash && c.canonicalPath != "" {
+		rs.redirectCanonical(c, c.canonicalPath)
+		return
+	}
 	if c.Match == nil {
+		allowed := rs.allowedMethods(c, c.Stash["path"].(string))
+		if len(allowed) > 0 {
+			c.Res.Headers.Add("Allow", strings.Join(allowed, ", "))
+			if c.Req.Method == "OPTIONS" {
+				c.Res.Code = 204
+				c.Res.Status = "No Content"
+				return
+			}
+			c.Res.Code = 405
+			c.Res.Status = "Method Not Allowed"
+			c.renderStatus("not_found", 405)
+			return
+		}
 		// XXX: Replace with Log
 		//fmt.Printf("[debug] 404 Not Found\n")
-		c.Res.Code = 404
-		c.Res.Status = "Not Found"
+		c.NotFound()
 		return
 	}
-	// Call handler in matched Route objects
-	// XXX: Does this handle async correctly?
+	// Call handler in matched Route objects. Delayed responses are
+	// handled by Application.Handler. See: Context.RenderLater
 	for _, r := range c.Match.Stack {
 		// XXX: Create mojo.Log w/ Error, Warning, Info, Debug, Trace
+		c.continueDispatch = true
+		for _, handler := range r.middleware {
+			handler(c)
+			if !c.continueDispatch {
+				return
+			}
+		}
 		if r.Handler != nil {
 			r.Handler(c)
 		}
@@ -265,11 +866,181 @@ func (rs *Routes) Dispatch(c *Context) {
 	}
 }
 
+// redirectCanonical redirects the request to the given canonical path,
+// keeping the query string
+func (rs *Routes) redirectCanonical(c *Context, path string) {
+	c.Match = nil
+	if c.Req.URL.RawQuery != "" {
+		path += "?" + c.Req.URL.RawQuery
+	}
+	code := 308
+	if c.Req.Method == "GET" || c.Req.Method == "HEAD" {
+		code = 301
+	}
+	c.Res.Redirect(path, code)
+}
+
+// Use adds middleware to the route. Middleware is called in the order it
+// was added, before the route's handler and the handlers of any routes
+// nested inside it. Middleware can stop dispatch with Context.Stop, like
+// the handlers given to Under.
+func (r *Route) Use(handlers ...Handler) *Route {
+	r.middleware = append(r.middleware, handlers...)
+	return r
+}
+
 func (r *Route) To(handler Handler) *Route {
 	r.Handler = handler
 	return r
 }
 
+// ToStatic sets the route's handler to serve the file at the given path
+// (relative to the Static paths, with or without a leading "/") from the
+// application's Static paths. Responds with Context.NotFound if the file
+// does not exist.
+func (r *Route) ToStatic(path string) *Route {
+	path = strings.TrimPrefix(path, "/")
+	r.Handler = func(c *Context) {
+		if !c.App.Static.Serve(c, path) {
+			c.NotFound()
+		}
+	}
+	return r
+}
+
+// Named sets a custom name for the route, to be used with Lookup and
+// Context.URLFor. Routes without a custom name get a name from their
+// pattern, like "useridedit" for "/user/:id/edit".
+func (r *Route) Named(name string) *Route {
+	r.Name = name
+	r.customName = true
+	return r
+}
+
+// Lookup finds the route with the given name in these routes or any
+// nested routes. Custom names take precedence over automatic names.
+// Returns nil if no route is found.
+func (rs *Routes) Lookup(name string) *Route {
+	var found *Route
+	rs.walk(func(r *Route) bool {
+		if r.Name != name {
+			return true
+		}
+		if r.customName {
+			found = r
+			return false
+		}
+		if found == nil {
+			found = r
+		}
+		return true
+	})
+	return found
+}
+
+// walk calls the given function for every route, depth-first, in the
+// order they were added. Walking stops if the function returns false.
+func (rs *Routes) walk(f func(*Route) bool) bool {
+	for _, r := range rs.routes {
+		if !f(r) {
+			return false
+		}
+		if r.Routes != nil && !r.Routes.walk(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// chain returns the routes from the top-level route down to this route.
+func (r *Route) chain() []*Route {
+	chain := []*Route{r}
+	for rs := r.parent; rs != nil && rs.owner != nil; rs = rs.owner.parent {
+		chain = append([]*Route{rs.owner}, chain...)
+	}
+	return chain
+}
+
+// URL builds a path for this route, filling in placeholders from the
+// given values and the route defaults. Optional placeholders at the end
+// of the path are left out if their value is the default. A "format"
+// value is added as a file extension if the route detects formats.
+// Panics if the route requires a format and none is given.
+func (r *Route) URL(values Stash) string {
+	defaults := r.defaults()
+	tokens := []patternToken{}
+	for _, cr := range r.chain() {
+		tokens = append(tokens, cr.tokens...)
+	}
+
+	// Remove trailing optional placeholders that have the default value
+	for len(tokens) > 0 {
+		token := tokens[len(tokens)-1]
+		def, optional := defaults[token.name]
+		if token.name == "" || !optional {
+			break
+		}
+		if value, ok := values[token.name]; ok && fmt.Sprint(value) != fmt.Sprint(def) {
+			break
+		}
+		tokens = tokens[:len(tokens)-1]
+	}
+	path := interpolate(tokens, values, defaults)
+
+	if len(r.routes) > 0 {
+		return path
+	}
+	formats, required := r.formatDetection()
+	if format := values["format"]; formats != "" && format != nil && fmt.Sprint(format) != "" {
+		path += "." + url.PathEscape(fmt.Sprint(format))
+	} else if required {
+		panic(fmt.Sprintf("Route %q requires a format", r.Name))
+	}
+	return path
+}
+
+// interpolate builds a path from the given pattern tokens, filling in
+// placeholders from the given values or defaults. Values are escaped
+// for use in a path.
+func interpolate(tokens []patternToken, values Stash, defaults Stash) string {
+	path := ""
+	for _, token := range tokens {
+		// Avoid doubled slashes when joining nested routes
+		if token.slash || strings.HasPrefix(token.text, "/") {
+			path = strings.TrimSuffix(path, "/")
+		}
+		if token.name == "" {
+			path += token.text
+			continue
+		}
+		if token.slash {
+			path += "/"
+		}
+		value, ok := values[token.name]
+		if !ok {
+			value = defaults[token.name]
+		}
+		str := ""
+		if value != nil {
+			str = fmt.Sprint(value)
+		}
+		if token.kind == '*' {
+			// Wildcards can contain "/"
+			parts := strings.Split(str, "/")
+			for i, part := range parts {
+				parts[i] = url.PathEscape(part)
+			}
+			path += strings.Join(parts, "/")
+		} else {
+			path += url.PathEscape(str)
+		}
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
 func (m *Match) Append(r *Route) {
 	m.Stack = append(m.Stack, r)
 }
@@ -1,7 +1,12 @@
 package mojo_test
 
 import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/preaction/mojo.go"
 	"github.com/preaction/mojo.go/testmojo"
@@ -196,3 +201,731 @@ func TestRoutesUnder(t *testing.T) {
 		}
 	})
 }
+
+func TestRoutesUse(t *testing.T) {
+	loggedIn := true
+	router := &mojo.Routes{}
+	admin := router.Any(nil, "/admin").Use(
+		func(c *mojo.Context) { c.Res.Text("Log\n") },
+		func(c *mojo.Context) {
+			if !loggedIn {
+				c.Res.Content.AddChunk([]byte("Denied\n"))
+				c.Stop()
+			}
+		},
+	)
+	admin.Get("/users").Use(func(c *mojo.Context) {
+		c.Res.Content.AddChunk([]byte("Load\n"))
+	}).To(func(c *mojo.Context) { c.Res.Content.AddChunk([]byte("Endpoint\n")) })
+
+	t.Run("Middleware runs before handlers", func(t *testing.T) {
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", "/admin/users"))
+		router.Dispatch(c)
+		if c.Res.Content.String() != "Log\nLoad\nEndpoint\n" {
+			t.Errorf("Got: %q", c.Res.Content.String())
+		}
+	})
+
+	t.Run("Middleware can stop dispatch", func(t *testing.T) {
+		defer func(val bool) { loggedIn = val }(loggedIn)
+		loggedIn = false
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", "/admin/users"))
+		router.Dispatch(c)
+		if c.Res.Content.String() != "Log\nDenied\n" {
+			t.Errorf("Got: %q", c.Res.Content.String())
+		}
+	})
+}
+
+func TestRoutesShortcut(t *testing.T) {
+	router := &mojo.Routes{}
+	router.AddShortcut("resource", func(r *mojo.Route, args ...interface{}) *mojo.Route {
+		name := args[0].(string)
+		resource := r.Any(nil, "/"+name)
+		resource.Get("/").Named(name + "_list").To(func(c *mojo.Context) { c.Res.Text("list " + name) })
+		resource.Get("/:id").Named(name + "_show").To(func(c *mojo.Context) {
+			c.Res.Text(fmt.Sprintf("show %s %s", name, c.Stash["id"]))
+		})
+		return resource
+	})
+
+	users := router.Shortcut("resource", "users")
+	if users == nil || router.Lookup("users_list") == nil {
+		t.Fatalf("Shortcut did not add routes")
+	}
+	// Shortcuts are available to nested routes
+	api := router.Any(nil, "/api")
+	api.Shortcut("resource", "posts")
+
+	tests := map[string]string{
+		"/users":        "list users",
+		"/users/1":      "show users 1",
+		"/api/posts/2":  "show posts 2",
+		"/api/posts/":   "list posts",
+		"/posts/2":      "",
+		"/api/users/10": "",
+	}
+	for path, expect := range tests {
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", path))
+		router.Dispatch(c)
+		if got := c.Res.Content.String(); got != expect {
+			t.Errorf("%s: Got: %q; Expect: %q", path, got, expect)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Unknown shortcut did not panic")
+		}
+	}()
+	router.Shortcut("unknown")
+}
+
+func TestRoutesMultiplePlaceholders(t *testing.T) {
+	gotStash := mojo.Stash{}
+	router := &mojo.Routes{}
+	router.Get("/:user/:repo").To(func(c *mojo.Context) { gotStash = c.Stash })
+
+	c := testmojo.NewContext(t, mojo.NewRequest("GET", "/preaction/mojo"))
+	router.Dispatch(c)
+
+	if gotStash["user"] != "preaction" {
+		t.Errorf(`Stash["user"] != "preaction"; Got: %v`, gotStash["user"])
+	}
+	if gotStash["repo"] != "mojo" {
+		t.Errorf(`Stash["repo"] != "mojo"; Got: %v`, gotStash["repo"])
+	}
+}
+
+func TestRoutesRelaxedPlaceholder(t *testing.T) {
+	tests := map[string]string{
+		"/user/#email":   "/user/fry@planex.com",
+		"/user/<#email>": "/user/fry@planex.com",
+	}
+	for pattern, path := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			email := ""
+			router := &mojo.Routes{}
+			router.Get(pattern).To(func(c *mojo.Context) { email = c.Stash["email"].(string) })
+
+			c := testmojo.NewContext(t, mojo.NewRequest("GET", path))
+			router.Dispatch(c)
+
+			if email != "fry@planex.com" {
+				t.Errorf(`Stash["email"] != "fry@planex.com"; Got: %s`, email)
+			}
+		})
+	}
+}
+
+func TestRoutesWildcardPlaceholder(t *testing.T) {
+	tests := map[string]string{
+		"/files/*file":          "/files/docs/intro.txt",
+		"/files/<*file>":        "/files/docs/intro.txt",
+		"/files/<*file>/editor": "/files/docs/intro.txt/editor",
+	}
+	for pattern, path := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			filePath := ""
+			router := &mojo.Routes{}
+			router.Get(pattern).To(func(c *mojo.Context) { filePath = c.Stash["file"].(string) })
+
+			c := testmojo.NewContext(t, mojo.NewRequest("GET", path))
+			router.Dispatch(c)
+
+			if filePath != "docs/intro.txt" {
+				t.Errorf(`Stash["file"] != "docs/intro.txt"; Got: %s`, filePath)
+			}
+		})
+	}
+
+	t.Run("Optional wildcard", func(t *testing.T) {
+		filePath := ""
+		router := &mojo.Routes{}
+		router.Get("/files/*file", mojo.Stash{"file": "index.html"}).To(func(c *mojo.Context) { filePath = c.Stash["file"].(string) })
+
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", "/files"))
+		router.Dispatch(c)
+		if filePath != "index.html" {
+			t.Errorf(`Stash["file"] != "index.html"; Got: %s`, filePath)
+		}
+
+		c = testmojo.NewContext(t, mojo.NewRequest("GET", "/files/docs/intro.txt"))
+		router.Dispatch(c)
+		if filePath != "docs/intro.txt" {
+			t.Errorf(`Stash["file"] != "docs/intro.txt"; Got: %s`, filePath)
+		}
+	})
+}
+
+func TestRoutesRestrictedPlaceholder(t *testing.T) {
+	format := ""
+	router := &mojo.Routes{}
+	router.Get("/report/:format", mojo.Stash{"format": []string{"json", "xml"}}).To(func(c *mojo.Context) { format = c.Stash["format"].(string) })
+
+	for _, expect := range []string{"json", "xml"} {
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", "/report/"+expect))
+		router.Dispatch(c)
+		if format != expect {
+			t.Errorf(`Stash["format"] != "%s"; Got: %s`, expect, format)
+		}
+	}
+
+	c := testmojo.NewContext(t, mojo.NewRequest("GET", "/report/html"))
+	router.Dispatch(c)
+	if c.Res.Code != 404 {
+		t.Errorf("Restricted placeholder matched disallowed value")
+	}
+
+	t.Run("Restricted with regexp", func(t *testing.T) {
+		router := &mojo.Routes{}
+		router.Get("/user/:id", mojo.Stash{"id": regexp.MustCompile(`[0-9]+`)})
+
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", "/user/1"))
+		router.Dispatch(c)
+		if c.Match == nil {
+			t.Errorf("Restricted placeholder did not match allowed value")
+		}
+
+		c = testmojo.NewContext(t, mojo.NewRequest("GET", "/user/fry"))
+		router.Dispatch(c)
+		if c.Match != nil {
+			t.Errorf("Restricted placeholder matched disallowed value")
+		}
+	})
+
+	t.Run("Defaults that are not placeholders", func(t *testing.T) {
+		router := &mojo.Routes{}
+		pattern := regexp.MustCompile(`^x`)
+		router.Get("/x", mojo.Stash{"tags": []string{"a", "b"}, "match": pattern})
+
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", "/x"))
+		router.Dispatch(c)
+		if c.Match == nil {
+			t.Fatalf("Route with slice default did not match")
+		}
+		if tags, ok := c.Stash["tags"].([]string); !ok || len(tags) != 2 {
+			t.Errorf(`Stash["tags"] default was dropped; Got: %v`, c.Stash["tags"])
+		}
+		if c.Stash["match"] != pattern {
+			t.Errorf(`Stash["match"] default was dropped; Got: %v`, c.Stash["match"])
+		}
+	})
+}
+
+func TestRoutesPlaceholderTypes(t *testing.T) {
+	router := &mojo.Routes{}
+	router.AddType("uuid", regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`))
+	router.Get("/item/<id:num>", mojo.Stash{"type": "item"})
+	router.Get("/session/<:id:uuid>", mojo.Stash{"type": "session"})
+
+	tests := []struct {
+		path   string
+		expect string
+	}{
+		{"/item/42", "item"},
+		{"/item/fortytwo", ""},
+		{"/session/0c7f3d6e-6f7c-4c2b-9c57-2a9d1f3c0b1a", "session"},
+		{"/session/fortytwo", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			c := testmojo.NewContext(t, mojo.NewRequest("GET", test.path))
+			router.Dispatch(c)
+			if test.expect == "" {
+				if c.Match != nil {
+					t.Errorf("Typed placeholder matched invalid value")
+				}
+				return
+			}
+			if c.Stash["type"] != test.expect {
+				t.Errorf(`Stash["type"] != "%s"; Got: %v`, test.expect, c.Stash["type"])
+			}
+		})
+	}
+
+	t.Run("Unknown type panics", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Route with unknown placeholder type did not panic")
+			}
+		}()
+		router.Get("/thing/<id:unknown>")
+	})
+
+	t.Run("Anchored type panics", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Anchored placeholder type did not panic")
+			}
+		}()
+		router.AddType("anchored", regexp.MustCompile(`^[a-z]+$`))
+	})
+}
+
+func TestRoutesLookup(t *testing.T) {
+	router := &mojo.Routes{}
+	auto := router.Get("/user/:id/edit")
+	about := router.Get("/about")
+	named := router.Get("/profile/:id").Named("useridedit")
+	under := router.Under("/admin", func(c *mojo.Context) bool { return true })
+	child := under.Get("/settings").Named("settings")
+
+	if router.Lookup("useridedit") != named {
+		t.Errorf("Custom name does not take precedence over automatic name")
+	}
+	if router.Lookup("about") != about {
+		t.Errorf("Could not find route by automatic name")
+	}
+	if router.Lookup("settings") != child {
+		t.Errorf("Could not find nested route by name")
+	}
+	if router.Lookup("missing") != nil {
+		t.Errorf("Found route for missing name")
+	}
+	if auto.Name != "useridedit" {
+		t.Errorf(`Automatic name != "useridedit"; Got: %s`, auto.Name)
+	}
+}
+
+func TestRouteURL(t *testing.T) {
+	router := &mojo.Routes{}
+	tests := []struct {
+		route  *mojo.Route
+		values mojo.Stash
+		expect string
+	}{
+		{router.Get("/"), nil, "/"},
+		{router.Get("/user/:id"), mojo.Stash{"id": 42}, "/user/42"},
+		{router.Get("/hello_<:name>"), mojo.Stash{"name": "world"}, "/hello_world"},
+		{router.Get("/files/*file"), mojo.Stash{"file": "docs/a b.txt"}, "/files/docs/a%20b.txt"},
+		{router.Get("/page/:page", mojo.Stash{"page": 1}), nil, "/page"},
+		{router.Get("/page/:page", mojo.Stash{"page": 1}), mojo.Stash{"page": 1}, "/page"},
+		{router.Get("/page/:page", mojo.Stash{"page": 1}), mojo.Stash{"page": 2}, "/page/2"},
+		{router.Get("/:a/:b", mojo.Stash{"a": "x", "b": "y"}), mojo.Stash{"a": "z"}, "/z"},
+		{router.Under("/admin", func(*mojo.Context) bool { return true }).Get("/user/:id"), mojo.Stash{"id": "fry"}, "/admin/user/fry"},
+		{router.Get("/report"), mojo.Stash{"format": "json"}, "/report.json"},
+		{router.Get("/user/:id"), mojo.Stash{"id": 42, "format": "json"}, "/user/42.json"},
+		{router.Get("/page/:page", mojo.Stash{"page": 1}), mojo.Stash{"format": "json"}, "/page.json"},
+		{router.Get("/data", mojo.Stash{"format": []string{"json"}}), mojo.Stash{"format": "json"}, "/data.json"},
+		{router.Get("/raw", mojo.Stash{"format": false}), mojo.Stash{"format": "json"}, "/raw"},
+		{router.Get("/export/:format"), mojo.Stash{"format": "txt"}, "/export/txt"},
+	}
+	for _, test := range tests {
+		got := test.route.URL(test.values)
+		if got != test.expect {
+			t.Errorf("URL(%v) != %s; Got: %s", test.values, test.expect, got)
+		}
+	}
+
+	t.Run("Generated URLs match the route", func(t *testing.T) {
+		for _, test := range tests {
+			c := testmojo.NewContext(t, mojo.NewRequest("GET", test.expect))
+			router.Dispatch(c)
+			if c.Match == nil {
+				t.Errorf("URL %s did not match a route", test.expect)
+			}
+		}
+	})
+
+	t.Run("Missing required format panics", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("URL without a required format did not panic")
+			}
+		}()
+		router.Get("/export", mojo.Stash{"format": []string{"csv"}}).URL(nil)
+	})
+}
+
+func TestRoutesFormatDetection(t *testing.T) {
+	router := &mojo.Routes{}
+	router.Get("/report")
+	router.Get("/raw", mojo.Stash{"format": false})
+	router.Get("/data", mojo.Stash{"format": []string{"json", "xml"}})
+	router.Get("/page", mojo.Stash{"format": "html"})
+	api := router.Under("/api", func(*mojo.Context) bool { return true }, mojo.Stash{"format": []string{"json"}})
+	api.Get("/users")
+
+	tests := []struct {
+		path   string
+		match  bool
+		format interface{}
+	}{
+		{"/report", true, nil},
+		{"/report.json", true, "json"},
+		{"/report.json/more", false, nil},
+		{"/report.exe", false, nil},
+		{"/raw", true, nil},
+		{"/raw.json", false, nil},
+		{"/data.xml", true, "xml"},
+		{"/data.html", false, nil},
+		{"/data", false, nil},
+		{"/page", true, "html"},
+		{"/page.txt", true, "txt"},
+		{"/api/users.json", true, "json"},
+		{"/api/users.html", false, nil},
+	}
+	for _, compiled := range []bool{false, true} {
+		if compiled {
+			router.Compile()
+		}
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%s compiled=%v", test.path, compiled), func(t *testing.T) {
+				c := testmojo.NewContext(t, mojo.NewRequest("GET", test.path))
+				router.Dispatch(c)
+				if !test.match {
+					if c.Res.Code != 404 {
+						t.Errorf("Incorrectly matched route")
+					}
+					return
+				}
+				if c.Match == nil {
+					t.Fatalf("No route found for request")
+				}
+				if c.Stash["format"] != test.format {
+					t.Errorf(`Stash["format"] != %v; Got: %v`, test.format, c.Stash["format"])
+				}
+			})
+		}
+	}
+}
+
+func TestRoutesAnchored(t *testing.T) {
+	router := &mojo.Routes{}
+	router.Get("/foo")
+	router.Under("/bar", func(*mojo.Context) bool { return true }).Get("/baz")
+
+	for _, path := range []string{"/foobar/anything", "/x/foo", "/barbaz", "/bar/bazz", "/x/bar/baz"} {
+		c := testmojo.NewContext(t, mojo.NewRequest("GET", path))
+		router.Dispatch(c)
+		if c.Res.Code != 404 {
+			t.Errorf("Incorrectly matched route for %s: %v", path, c.Match.Stack)
+		}
+	}
+}
+
+func TestRoutesNested(t *testing.T) {
+	got := ""
+	router := &mojo.Routes{}
+	users := router.Get("/users", mojo.Stash{"page": "1"})
+	users.Any(nil, "/").To(func(c *mojo.Context) { got = "list page " + c.Param("page") })
+	users.Any(nil, "/:id").To(func(c *mojo.Context) { got = "show " + c.Param("id") })
+	users.Post("/:id/edit").To(func(c *mojo.Context) { got = "edit " + c.Param("id") })
+	root := router.Under("/", func(c *mojo.Context) bool { c.Stash["under"] = "root"; return true })
+	root.Get("/about").To(func(c *mojo.Context) { got = "about " + c.Param("under") })
+
+	tests := []struct {
+		method string
+		path   string
+		expect string
+	}{
+		{"GET", "/users", "list page 1"},
+		{"GET", "/users/", "list page 1"},
+		{"GET", "/users/fry", "show fry"},
+		{"POST", "/users/fry", ""},
+		{"GET", "/users/fry/edit", ""},
+		{"POST", "/users/fry/edit", ""},
+		{"GET", "/about", "about root"},
+	}
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			got = ""
+			c := testmojo.NewContext(t, mojo.NewRequest(test.method, test.path))
+			router.Dispatch(c)
+			if got != test.expect {
+				t.Errorf("Got: %q; Expect: %q", got, test.expect)
+			}
+			if test.expect == "" && c.Match != nil {
+				t.Errorf("Incorrectly matched route")
+			}
+		})
+	}
+}
+
+func TestRoutesMethodNotAllowed(t *testing.T) {
+	router := &mojo.Routes{}
+	router.Get("/user/:id")
+	router.Put("/user/:id")
+	router.Delete("/user/:id")
+	router.Post("/users")
+
+	c := testmojo.NewContext(t, mojo.NewRequest("POST", "/user/fry"))
+	router.Dispatch(c)
+	if c.Res.Code != 405 {
+		t.Errorf("Status %d != 405", c.Res.Code)
+	}
+	expect := "DELETE, GET, HEAD, OPTIONS, PUT"
+	if got := c.Res.Headers.Header("Allow"); got != expect {
+		t.Errorf("Allow header != %q; Got: %q", expect, got)
+	}
+
+	t.Run("Renders the not_found template", func(t *testing.T) {
+		app := mojo.NewApplication()
+		app.Routes.Get("/user/:id")
+		c := app.BuildContext(mojo.NewRequest("POST", "/user/fry"), nil)
+		app.Handler(c)
+		if c.Res.Code != 405 {
+			t.Errorf("Status %d != 405", c.Res.Code)
+		}
+		body := c.Res.Content.String()
+		if !strings.Contains(body, "Method not allowed") || !strings.Contains(body, "GET, HEAD, OPTIONS") {
+			t.Errorf("405 did not render the not_found page. Got: %q", body)
+		}
+	})
+
+	t.Run("Path mismatch is still Not Found", func(t *testing.T) {
+		c := testmojo.NewContext(t, mojo.NewRequest("POST", "/user"))
+		router.Dispatch(c)
+		if c.Res.Code != 404 {
+			t.Errorf("Status %d != 404", c.Res.Code)
+		}
+	})
+
+	t.Run("OPTIONS returns allowed methods", func(t *testing.T) {
+		c := testmojo.NewContext(t, mojo.NewRequest("OPTIONS", "/users"))
+		router.Dispatch(c)
+		if c.Res.Code != 204 {
+			t.Errorf("Status %d != 204", c.Res.Code)
+		}
+		if got := c.Res.Headers.Header("Allow"); got != "OPTIONS, POST" {
+			t.Errorf(`Allow header != "OPTIONS, POST"; Got: %q`, got)
+		}
+	})
+
+	t.Run("HEAD is handled by GET routes", func(t *testing.T) {
+		c := testmojo.NewContext(t, mojo.NewRequest("HEAD", "/user/fry"))
+		router.Dispatch(c)
+		if c.Match == nil {
+			t.Errorf("HEAD request did not match GET route")
+		}
+	})
+}
+
+// buildTestRoutes builds a set of routes covering all the placeholder
+// and nesting features
+func buildTestRoutes() *mojo.Routes {
+	router := &mojo.Routes{}
+	router.AddType("hex", regexp.MustCompile(`[0-9a-f]+`))
+	router.Get("/")
+	router.Get("/about").Named("about")
+	router.Get("/hello.txt").Named("hellotxt")
+	router.Get("/user/:id").Named("user")
+	router.Put("/user/:id").Named("updateuser")
+	router.Get("/user/:id/posts/#post").Named("post")
+	router.Get("/files/*file").Named("files")
+	router.Get("/docs/*page", mojo.Stash{"page": "index"}).Named("docs")
+	router.Get("/hello_<:name>").Named("hello")
+	router.Get("/item/<id:num>").Named("item")
+	router.Get("/color/<id:hex>").Named("color")
+	router.Get("/report/:type", mojo.Stash{"type": []string{"daily", "weekly"}}).Named("report")
+	router.Get("/page/:page", mojo.Stash{"page": "1"}).Named("page")
+	router.Get("/data", mojo.Stash{"format": []string{"json", "xml"}}).Named("data")
+	router.Get("/raw", mojo.Stash{"format": false}).Named("raw")
+	router.Get(`/regexp/(?P<year>\d{4})`).Named("regexp")
+	router.Get(`/regexp/(a|b)(?P<day>\d{2})`).Named("regexpgroup")
+	router.Get("/re/:id", mojo.Stash{"id": regexp.MustCompile(`(a|b)c`)}).Named("re")
+	router.Get("/re/<id:hex>/:tag", mojo.Stash{"tag": regexp.MustCompile(`x(y|z)`)}).Named("regroups")
+	router.Get("/host").Requires("host", "example.com").Named("hostexample")
+	router.Get("/host").Named("hostdefault")
+	users := router.Any(nil, "/users")
+	users.Get("/").Named("users")
+	users.Get("/:id", mojo.Stash{"tab": "profile"}).Named("usersid")
+	users.Post("/:id/edit").Named("usersedit")
+	admin := router.Under("/admin/", func(*mojo.Context) bool { return true })
+	admin.Get("/").Named("admin")
+	admin.Get("/:section", mojo.Stash{"section": "dashboard"}).Named("adminsection")
+	lang := router.Under("/:lang", func(*mojo.Context) bool { return true }, mojo.Stash{"lang": []string{"en", "de"}})
+	lang.Get("/", mojo.Stash{"format": "html"}).Named("langindex")
+	lang.Get("/news/:id").Named("langnews")
+	router.Any(nil, "/*catchall").Named("catchall")
+	return router
+}
+
+func TestRoutesCompile(t *testing.T) {
+	plain := buildTestRoutes()
+	compiled := buildTestRoutes()
+	compiled.Compile()
+
+	paths := []string{
+		"/", "/about", "/about/", "/about.json", "/abouts", "/hello.txt", "/helloXtxt",
+		"/user/fry", "/user/fry.json", "/user/fry/", "/user/fry.json.json", "/user/fry/posts/a.b.c",
+		"/files/a/b/c.txt", "/files/", "/files", "/docs", "/docs/", "/docs/intro/install.html",
+		"/hello_world", "/hello_world.txt", "/hello_", "/item/42", "/item/42.json", "/item/fortytwo",
+		"/color/ff00ff", "/color/red", "/report/daily", "/report/monthly", "/report/weekly.csv",
+		"/page", "/page/", "/page/2", "/page.json", "/page/2.json", "/data.json", "/data", "/data.html",
+		"/raw", "/raw.json", "/regexp/2024", "/regexp/24", "/host", "/users", "/users/", "/users/fry",
+		"/users/fry.json", "/users.json", "/users/.json", "/users/fry/edit", "/admin", "/admin/",
+		"/admin/settings", "/admin/settings.html", "/en", "/en/", "/en.html", "/de/news/1", "/fr/news/1",
+		"/en/news/1.json", "/users/fry/", "/admin/settings/", "//", "/en/news/", "/files/.json", "/item/42/",
+		"/page/.json", "/user/.json", "/docs.json", "/docs/.json", "/hello_.json", "/report/daily.json.json",
+		"/regexp/a12", "/regexp/c12", "/re/ac", "/re/bc", "/re/abc", "/re/ff/xy", "/re/ff/xa",
+	}
+	for _, method := range []string{"GET", "POST", "PUT", "HEAD"} {
+		for _, path := range paths {
+			for _, host := range []string{"example.com", "example.net"} {
+				t.Run(method+" "+host+path, func(t *testing.T) {
+					expect := testmojo.NewContext(t, mojo.NewRequest(method, path))
+					expect.Req.Headers.Add("Host", host)
+					plain.Dispatch(expect)
+					got := testmojo.NewContext(t, mojo.NewRequest(method, path))
+					got.Req.Headers.Add("Host", host)
+					compiled.Dispatch(got)
+
+					if got.Res.Code != expect.Res.Code {
+						t.Errorf("Status %d != %d", got.Res.Code, expect.Res.Code)
+					}
+					if got.Res.Headers.Header("Allow") != expect.Res.Headers.Header("Allow") {
+						t.Errorf("Allow %q != %q", got.Res.Headers.Header("Allow"), expect.Res.Headers.Header("Allow"))
+					}
+					if expect.Match == nil || got.Match == nil {
+						if expect.Match != got.Match {
+							t.Errorf("Compiled routes matched %v; Expect: %v", got.Match, expect.Match)
+						}
+						return
+					}
+					gotName := got.Match.Stack[len(got.Match.Stack)-1].Name
+					expectName := expect.Match.Stack[len(expect.Match.Stack)-1].Name
+					if gotName != expectName {
+						t.Errorf("Compiled routes matched %s; Expect: %s", gotName, expectName)
+					}
+					if fmt.Sprint(got.Stash) != fmt.Sprint(expect.Stash) {
+						t.Errorf("Stash %v != %v", got.Stash, expect.Stash)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestRoutesAnchoredRestriction(t *testing.T) {
+	for _, pattern := range []string{`^a.*$`, `a.*$`, `^a`, `(?m)^a`, `\Aa\z`} {
+		t.Run(pattern, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Anchored restriction did not panic")
+				}
+			}()
+			router := &mojo.Routes{}
+			router.Get("/re/:id", mojo.Stash{"id": regexp.MustCompile(pattern)})
+		})
+	}
+}
+
+func TestRoutesCompileAddRoute(t *testing.T) {
+	router := &mojo.Routes{}
+	router.Get("/foo")
+	router.Compile()
+
+	c := testmojo.NewContext(t, mojo.NewRequest("GET", "/bar"))
+	router.Dispatch(c)
+	if c.Match != nil {
+		t.Fatalf("Incorrectly matched route")
+	}
+
+	router.Get("/bar")
+	c = testmojo.NewContext(t, mojo.NewRequest("GET", "/bar"))
+	router.Dispatch(c)
+	if c.Match == nil {
+		t.Errorf("Route added after Compile was not matched")
+	}
+}
+
+// buildBenchmarkRoutes builds a large set of routes for benchmarks
+func buildBenchmarkRoutes() *mojo.Routes {
+	router := &mojo.Routes{}
+	for i := 0; i < 100; i++ {
+		resource := fmt.Sprintf("/resource%d", i)
+		router.Get(resource)
+		router.Get(resource + "/:id")
+		router.Put(resource + "/:id")
+		router.Get(resource + "/:id/edit")
+		router.Get(resource + "/:id/files/*file")
+	}
+	return router
+}
+
+func benchmarkRoutes(b *testing.B, router *mojo.Routes, paths []string) {
+	contexts := make([]*mojo.Context, len(paths))
+	for i, path := range paths {
+		contexts[i] = &mojo.Context{Req: mojo.NewRequest("GET", path), Res: mojo.NewResponse(), Stash: mojo.Stash{}}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := contexts[i%len(contexts)]
+		c.Match = nil
+		c.Stash = mojo.Stash{"path": c.Req.URL.Path}
+		router.Match(c)
+		if c.Match == nil {
+			b.Fatalf("No route found for %s", c.Req.URL.Path)
+		}
+	}
+}
+
+var benchmarkPaths = []string{"/resource0", "/resource50/42", "/resource99/42/edit", "/resource99/42/files/a/b.txt"}
+
+func BenchmarkRoutesMatch(b *testing.B) {
+	benchmarkRoutes(b, buildBenchmarkRoutes(), benchmarkPaths)
+}
+
+func BenchmarkRoutesMatchCompiled(b *testing.B) {
+	router := buildBenchmarkRoutes()
+	router.Compile()
+	benchmarkRoutes(b, router, benchmarkPaths)
+}
+
+func BenchmarkRoutesMatchCompiledUncached(b *testing.B) {
+	router := buildBenchmarkRoutes()
+	router.Compile()
+	// More distinct paths than the cache can hold
+	paths := []string{}
+	for i := 0; i < 2000; i++ {
+		paths = append(paths, fmt.Sprintf("/resource%d/%d/edit", i%100, i))
+	}
+	benchmarkRoutes(b, router, paths)
+}
+
+func TestRoutesRedirect(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Routes.Get("/posts/:id").Named("post")
+	app.Routes.Redirect("/old", "/new", 0)
+	app.Routes.Redirect("/blog/:id", "/posts/:id", 301)
+	app.Routes.Redirect("/p/:id", "post", 308)
+	app.Routes.Redirect("/files/*path", "https://example.com/<*path>", 0)
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/old", 302, "/new"},
+		{"/blog/42", 301, "/posts/42"},
+		{"/p/7", 308, "/posts/7"},
+		{"/files/a/b c.txt", 302, "https://example.com/a/b%20c.txt"},
+	}
+	for _, tt := range tests {
+		mt := testmojo.NewTester(t, app)
+		mt.GetOk(tt.path).StatusIs(tt.code)
+		if location := mt.Context.Res.Headers.Header("Location"); location != tt.location {
+			t.Errorf("%s: Location: %q; Expect: %q", tt.path, location, tt.location)
+		}
+	}
+}
+
+func TestRouteToStatic(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Static.Paths = []fs.FS{fstest.MapFS{
+		"robots.txt": &fstest.MapFile{Data: []byte("User-agent: *"), Mode: 0644},
+	}}
+	app.Routes.Get("/robots").ToStatic("robots.txt")
+	app.Routes.Get("/robots.txt").ToStatic("/robots.txt")
+	app.Routes.Get("/missing").ToStatic("missing.txt")
+
+	mt := testmojo.NewTester(t, app)
+	mt.GetOk("/robots").StatusIs(200).TextIs("User-agent: *")
+	mt.GetOk("/robots.txt").StatusIs(200).TextIs("User-agent: *")
+	mt.GetOk("/missing").StatusIs(404)
+	if body := mt.Context.Res.Content.String(); !strings.Contains(body, "Page not found") {
+		t.Errorf("Missing static file did not render not_found page. Got: %q", body)
+	}
+}
@@ -0,0 +1,595 @@
+package mojo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// routeCacheSize is the maximum number of method and path pairs kept in
+// the match cache of a compiled route tree
+const routeCacheSize = 1024
+
+// Compile builds a tree from the routes to speed up matching when an
+// application has many routes. Instead of trying the pattern of every
+// route in order, the tree matches the request path one segment at
+// a time, and remembers which routes match recently-requested methods
+// and paths.
+//
+// Static text and placeholders are matched by the tree. Routes with
+// regular expressions in their pattern (including literal "." characters)
+// and routes that cannot be split into path segments (like optional
+// placeholders that are not at the end of the path) are matched with
+// their regular expression instead. Restricted placeholders match only
+// a single path segment in the tree. Either way, the first route added
+// that matches the request wins, just like uncompiled routes.
+//
+// Routes added after Compile is called are added to the tree the next
+// time a request is matched.
+func (rs *Routes) Compile() {
+	root := rs.root()
+	root.compiled = true
+	root.routeTree()
+}
+
+// resetTree removes the compiled route tree, so that it will be built
+// again with the current routes.
+func (rs *Routes) resetTree() {
+	rs.treeLock.Lock()
+	defer rs.treeLock.Unlock()
+	rs.tree = nil
+}
+
+// routeTree returns the compiled route tree, building it if needed
+func (rs *Routes) routeTree() *routeTree {
+	rs.treeLock.Lock()
+	defer rs.treeLock.Unlock()
+	if rs.tree == nil {
+		rs.tree = newRouteTree(rs)
+	}
+	return rs.tree
+}
+
+// routeTree is a compiled prefix tree of routes, split by path segment
+type routeTree struct {
+	root      *treeNode
+	fallback  []*routeChain
+	cache     map[string][]treeMatch
+	cacheLock sync.RWMutex
+}
+
+// routeChain is an endpoint route and the routes it is nested under,
+// from the top-level route to the endpoint
+type routeChain struct {
+	order  int
+	routes []*Route
+}
+
+// treeMatch is a chain that matches a request path, with the stash
+// values from the match
+type treeMatch struct {
+	chain *routeChain
+	stash Stash
+	pass  int // 0 for the whole path, 1 with the format removed
+}
+
+// treeNode is a node in the route tree. Each edge matches a single path
+// segment.
+type treeNode struct {
+	static map[treeKey]*treeNode
+	params []*treeParam
+	leaves []*treeLeaf
+}
+
+// treeKey is the key for an edge matching a static path segment
+type treeKey struct {
+	text  string
+	start bool
+}
+
+// treeParam is an edge matching a path segment with placeholders
+type treeParam struct {
+	key      string
+	start    bool           // Segment starts a nested route, so it can match an empty path
+	kind     byte           // ':', '#', or '*' for a single placeholder, 0 for a pattern
+	name     string         // The placeholder name
+	level    int            // The index of the placeholder's route in the chain
+	optional bool           // The placeholder can be empty
+	pattern  *regexp.Regexp // The restriction for a placeholder, or the segment pattern
+	levels   []int          // The route index for each capture group in pattern
+	node     *treeNode
+}
+
+// treeLeaf is an endpoint in the route tree
+type treeLeaf struct {
+	chain          *routeChain
+	format         *regexp.Regexp // Detected formats, or nil if disabled
+	formatRequired bool
+	noFormat       bool // Leaf is reached without a nested route's "/"
+}
+
+// treeToken is a pattern token for one of the routes in a chain
+type treeToken struct {
+	patternToken
+	level  int
+	start  bool // First token of a nested route
+	shared bool // The "/" before the token was removed from the parent route
+}
+
+// treeSegment is the tokens for a single path segment in a chain
+type treeSegment struct {
+	tokens []treeToken
+	start  bool
+	shared bool
+}
+
+// treeCapture is a placeholder value captured by the route tree
+type treeCapture struct {
+	level int
+	name  string
+	value string
+}
+
+// newRouteTree builds a route tree from the given routes
+func newRouteTree(rs *Routes) *routeTree {
+	t := &routeTree{root: &treeNode{}, cache: map[string][]treeMatch{}}
+	order := 0
+	var visit func(rs *Routes, parents []*Route)
+	visit = func(rs *Routes, parents []*Route) {
+		for _, r := range rs.routes {
+			chain := append(append([]*Route{}, parents...), r)
+			if len(r.routes) > 0 {
+				visit(r.Routes, chain)
+				continue
+			}
+			rc := &routeChain{order: order, routes: chain}
+			order++
+			if !t.insert(rc) {
+				t.fallback = append(t.fallback, rc)
+			}
+		}
+	}
+	visit(rs, nil)
+	return t
+}
+
+// segments splits the patterns of the routes in the chain into path
+// segments. Returns false if the patterns cannot be split.
+func (rc *routeChain) segments() ([]treeSegment, bool) {
+	// Join the tokens of all the routes, removing any doubled "/"
+	// between nested routes like the regexp matcher does
+	tokens := []treeToken{}
+	for level, r := range rc.routes {
+		if len(r.tokens) == 0 {
+			return nil, false
+		}
+		first := r.tokens[0]
+		if !first.slash && !strings.HasPrefix(first.text, "/") {
+			return nil, false
+		}
+		start, shared := level > 0, false
+		if n := len(tokens) - 1; n >= 0 && tokens[n].name == "" && strings.HasSuffix(tokens[n].text, "/") {
+			tokens[n].text = strings.TrimSuffix(tokens[n].text, "/")
+			if tokens[n].text == "" {
+				tokens = tokens[:n]
+			}
+			start, shared = false, true
+		}
+		for i, token := range r.tokens {
+			if token.name == "" && regexp.QuoteMeta(token.text) != token.text {
+				return nil, false
+			}
+			tokens = append(tokens, treeToken{patternToken: token, level: level, start: start && i == 0, shared: shared && i == 0})
+		}
+	}
+
+	segments := []treeSegment{}
+	for _, token := range tokens {
+		if token.name != "" {
+			if token.slash {
+				segments = append(segments, treeSegment{start: token.start, shared: token.shared})
+			}
+			if len(segments) == 0 {
+				return nil, false
+			}
+			segment := &segments[len(segments)-1]
+			segment.tokens = append(segment.tokens, token)
+			continue
+		}
+		for i, part := range strings.Split(token.text, "/") {
+			if i > 0 {
+				segments = append(segments, treeSegment{start: token.start && i == 1, shared: token.shared && i == 1})
+			}
+			if part == "" {
+				continue
+			}
+			if len(segments) == 0 {
+				return nil, false
+			}
+			segment := &segments[len(segments)-1]
+			literal := token
+			literal.text = part
+			segment.tokens = append(segment.tokens, literal)
+		}
+	}
+	return segments, true
+}
+
+// defaults returns the route defaults for the routes in the chain, up to
+// and including the given level
+func (rc *routeChain) defaults(level int) Stash {
+	defaults := Stash{}
+	for _, r := range rc.routes[:level+1] {
+		defaults.Merge(r.Defaults)
+	}
+	return defaults
+}
+
+// restriction returns the pattern restricting the values of the given
+// placeholder, or the empty string if it is not restricted
+func (rc *routeChain) restriction(token treeToken) string {
+	r := rc.routes[token.level]
+	if value, ok := r.restrictions[token.name]; ok {
+		return restrictionPattern(value)
+	}
+	if token.typ != "" {
+		return r.parent.lookupType(token.typ).String()
+	}
+	return ""
+}
+
+// insert adds the chain to the tree. Returns false if the chain cannot be
+// matched by the tree.
+func (t *routeTree) insert(rc *routeChain) bool {
+	segments, ok := rc.segments()
+	if !ok {
+		return false
+	}
+
+	leaf := &treeLeaf{chain: rc}
+	endpoint := rc.routes[len(rc.routes)-1]
+	if formats, required := endpoint.formatDetection(); formats != "" {
+		leaf.format = regexp.MustCompile(fmt.Sprintf("^(?:%s)$", formats))
+		leaf.formatRequired = required
+	}
+
+	edges := []*treeParam{}
+	var optional *treeSegment
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		param, ok := rc.edge(segment, last)
+		if !ok {
+			return false
+		}
+		if param != nil && param.optional {
+			optional = &segments[i]
+		}
+		edges = append(edges, param)
+	}
+
+	// Optional placeholders also match without their segment, unless
+	// the "/" before them belongs to the parent route
+	if optional != nil && !optional.shared {
+		short := *leaf
+		short.noFormat = optional.start
+		t.root.insert(segments[:len(segments)-1], edges[:len(edges)-1], &short)
+	}
+	t.root.insert(segments, edges, leaf)
+	return true
+}
+
+// edge returns the edge for the given path segment, or nil if the
+// segment is static text. Returns false if the segment cannot be
+// matched by the tree.
+func (rc *routeChain) edge(segment treeSegment, last bool) (*treeParam, bool) {
+	placeholders := 0
+	for _, token := range segment.tokens {
+		if token.name != "" {
+			placeholders++
+		}
+	}
+	if placeholders == 0 {
+		return nil, true
+	}
+
+	// Single placeholder
+	if len(segment.tokens) == 1 {
+		token := segment.tokens[0]
+		_, optional := rc.defaults(token.level)[token.name]
+		if (optional || token.kind == '*') && !last {
+			return nil, false
+		}
+		param := &treeParam{
+			start:    segment.start,
+			kind:     token.kind,
+			name:     token.name,
+			level:    token.level,
+			optional: optional,
+		}
+		if restriction := rc.restriction(token); restriction != "" {
+			param.pattern = regexp.MustCompile(fmt.Sprintf("^(?:%s)$", restriction))
+		}
+		param.key = fmt.Sprintf("%t %c %s %d %t %v", param.start, param.kind, param.name, param.level, param.optional, param.pattern)
+		return param, true
+	}
+
+	// Text and placeholders in the same segment
+	pattern := ""
+	levels := []int{0}
+	for _, token := range segment.tokens {
+		if token.name == "" {
+			pattern += token.text
+			continue
+		}
+		if _, optional := rc.defaults(token.level)[token.name]; optional || token.kind == '*' {
+			return nil, false
+		}
+		match := placeholderPatterns[token.kind] + "+"
+		if restriction := rc.restriction(token); restriction != "" {
+			match = "(?:" + restriction + ")"
+		}
+		pattern += fmt.Sprintf("(?P<%s>%s)", token.name, match)
+		levels = append(levels, token.level)
+	}
+	param := &treeParam{
+		start:   segment.start,
+		pattern: regexp.MustCompile("^" + pattern + "$"),
+		levels:  levels,
+	}
+	if param.pattern.NumSubexp() != len(levels)-1 {
+		// Restrictions with their own capture groups
+		return nil, false
+	}
+	param.key = fmt.Sprintf("%t %v %v", param.start, param.pattern, levels)
+	return param, true
+}
+
+// insert adds the given segments to the tree below this node, with the
+// given leaf at the end
+func (n *treeNode) insert(segments []treeSegment, edges []*treeParam, leaf *treeLeaf) {
+	if len(segments) == 0 {
+		n.leaves = append(n.leaves, leaf)
+		return
+	}
+	segment, edge := segments[0], edges[0]
+
+	var next *treeNode
+	if edge == nil {
+		text := ""
+		for _, token := range segment.tokens {
+			text += token.text
+		}
+		key := treeKey{text: text, start: segment.start}
+		if n.static == nil {
+			n.static = map[treeKey]*treeNode{}
+		}
+		if _, ok := n.static[key]; !ok {
+			n.static[key] = &treeNode{}
+		}
+		next = n.static[key]
+	} else {
+		for _, param := range n.params {
+			if param.key == edge.key {
+				next = param.node
+				break
+			}
+		}
+		if next == nil {
+			edge.node = &treeNode{}
+			n.params = append(n.params, edge)
+			next = edge.node
+		}
+	}
+	next.insert(segments[1:], edges[1:], leaf)
+}
+
+// match checks the given path segment value, returning any placeholder
+// values
+func (p *treeParam) match(value string) ([]treeCapture, bool) {
+	if p.kind == 0 {
+		match := p.pattern.FindStringSubmatch(value)
+		if match == nil {
+			return nil, false
+		}
+		captures := []treeCapture{}
+		for i, name := range p.pattern.SubexpNames() {
+			if i > 0 {
+				captures = append(captures, treeCapture{level: p.levels[i], name: name, value: match[i]})
+			}
+		}
+		return captures, true
+	}
+
+	if value == "" && !p.optional {
+		return nil, false
+	}
+	if p.pattern != nil {
+		if value != "" && !p.pattern.MatchString(value) {
+			return nil, false
+		}
+	} else if p.kind == ':' && strings.Contains(value, ".") {
+		return nil, false
+	}
+	return []treeCapture{{level: p.level, name: p.name, value: value}}, true
+}
+
+// lookup finds the leaves matching the given path segments below this
+// node. If virtual is true, nested routes can match an empty path as
+// "/".
+func (n *treeNode) lookup(segments []string, virtual bool, captures []treeCapture, found func(*treeLeaf, []treeCapture)) {
+	// Make sure appending to captures always copies
+	captures = captures[:len(captures):len(captures)]
+
+	if len(segments) == 0 {
+		for _, leaf := range n.leaves {
+			found(leaf, captures)
+		}
+		if !virtual {
+			return
+		}
+		if child, ok := n.static[treeKey{start: true}]; ok {
+			child.lookup(segments, false, captures, found)
+		}
+		for _, param := range n.params {
+			if !param.start {
+				continue
+			}
+			if values, ok := param.match(""); ok {
+				param.node.lookup(segments, false, append(captures, values...), found)
+			}
+		}
+		return
+	}
+
+	segment := segments[0]
+	for _, start := range []bool{false, true} {
+		if child, ok := n.static[treeKey{text: segment, start: start}]; ok {
+			child.lookup(segments[1:], virtual, captures, found)
+		}
+	}
+	for _, param := range n.params {
+		if param.kind == '*' {
+			// Wildcards match the rest of the path
+			if values, ok := param.match(strings.Join(segments, "/")); ok {
+				param.node.lookup(nil, false, append(captures, values...), found)
+			}
+			continue
+		}
+		if values, ok := param.match(segment); ok {
+			param.node.lookup(segments[1:], virtual, append(captures, values...), found)
+		}
+	}
+}
+
+// allowsMethod returns true if every route in the chain allows the given
+// method
+func (rc *routeChain) allowsMethod(method string) bool {
+	for _, r := range rc.routes {
+		if !r.allowsMethod(method) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkConditions returns true if the conditions for every route in the
+// chain pass
+func (rc *routeChain) checkConditions(c *Context) bool {
+	for _, r := range rc.routes {
+		if !r.checkConditions(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// stash builds the stash for the chain from the given placeholder
+// values
+func (rc *routeChain) stash(captures []treeCapture, format string) Stash {
+	stash := Stash{}
+	for level := range rc.routes {
+		for _, capture := range captures {
+			if capture.level == level && capture.value != "" {
+				stash[capture.name] = capture.value
+			}
+		}
+	}
+	if format != "" {
+		stash["format"] = format
+	}
+	return stash
+}
+
+// matchPath matches the chain's regular expressions against the given
+// path, like Routes.match.
+func (rc *routeChain) matchPath(path string) (Stash, bool) {
+	stash := Stash{}
+	for _, r := range rc.routes {
+		rest, ok := r.matchPath(path, stash)
+		if !ok {
+			return nil, false
+		}
+		path = rest
+	}
+	return stash, true
+}
+
+// candidates returns the chains that match the given method and path,
+// in the order they should be tried.
+func (t *routeTree) candidates(method string, path string) []treeMatch {
+	key := method + " " + path
+	t.cacheLock.RLock()
+	matches, ok := t.cache[key]
+	t.cacheLock.RUnlock()
+	if ok {
+		return matches
+	}
+
+	matches = []treeMatch{}
+	if strings.HasPrefix(path, "/") {
+		segments := strings.Split(path[1:], "/")
+		t.root.lookup(segments, true, nil, func(leaf *treeLeaf, captures []treeCapture) {
+			if leaf.formatRequired || !leaf.chain.allowsMethod(method) {
+				return
+			}
+			matches = append(matches, treeMatch{chain: leaf.chain, stash: leaf.chain.stash(captures, "")})
+		})
+
+		// Try again with the format removed from the end of the path
+		last := segments[len(segments)-1]
+		if dot := strings.LastIndexByte(last, '.'); dot >= 0 && dot < len(last)-1 {
+			format := last[dot+1:]
+			segments = append(segments[:len(segments)-1:len(segments)-1], last[:dot])
+			t.root.lookup(segments, false, nil, func(leaf *treeLeaf, captures []treeCapture) {
+				if leaf.format == nil || leaf.noFormat || !leaf.format.MatchString(format) || !leaf.chain.allowsMethod(method) {
+					return
+				}
+				matches = append(matches, treeMatch{chain: leaf.chain, stash: leaf.chain.stash(captures, format), pass: 1})
+			})
+		}
+	}
+
+	for _, rc := range t.fallback {
+		if !rc.allowsMethod(method) {
+			continue
+		}
+		if stash, ok := rc.matchPath(path); ok {
+			matches = append(matches, treeMatch{chain: rc, stash: stash})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].chain.order != matches[j].chain.order {
+			return matches[i].chain.order < matches[j].chain.order
+		}
+		return matches[i].pass < matches[j].pass
+	})
+
+	t.cacheLock.Lock()
+	if len(t.cache) >= routeCacheSize {
+		t.cache = map[string][]treeMatch{}
+	}
+	t.cache[key] = matches
+	t.cacheLock.Unlock()
+	return matches
+}
+
+// match finds the first chain that matches the given method and path and
+// whose conditions pass for the given context. See: Routes.match
+func (t *routeTree) match(c *Context, method string, path string) ([]*Route, Stash) {
+	for _, m := range t.candidates(method, path) {
+		if !m.chain.checkConditions(c) {
+			continue
+		}
+		endpoint := m.chain.routes[len(m.chain.routes)-1]
+		if format, ok := m.stash["format"].(string); ok && !endpoint.allowsFormat(c, format) {
+			continue
+		}
+		stash := Stash{}
+		stash.Merge(m.stash)
+		return m.chain.routes, stash
+	}
+	return nil, nil
+}
@@ -0,0 +1,303 @@
+package mojo
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session is the data stored for a user between requests. The data is
+// encoded as JSON, so numbers come back as float64 and structs come
+// back as maps. See: Context.Session
+type Session map[string]interface{}
+
+// Sessions configures the cookie used to store sessions. Without a Store,
+// the session is encoded as JSON, base64-encoded, and signed with the
+// first of the Application.Secrets. With a Store, the session is saved
+// in the Store and the cookie holds the signed session ID.
+type Sessions struct {
+	// CookieName is the name of the session cookie. Defaults to "mojo".
+	CookieName string
+	// Path is the path the cookie is valid for. Defaults to "/".
+	Path string
+	// Domain is the domain the cookie is valid for. Defaults to the
+	// current host only.
+	Domain string
+	// Expiration is how long the session lasts after the last request
+	// that used it. Zero means the session lasts until the browser is
+	// closed. Defaults to one hour.
+	Expiration time.Duration
+	// Secure sends the cookie only over HTTPS
+	Secure bool
+	// HttpOnly hides the cookie from JavaScript. Defaults to true.
+	HttpOnly bool
+	// SameSite sets the cookie's SameSite attribute. Defaults to
+	// http.SameSiteLaxMode.
+	SameSite http.SameSite
+
+	// Store saves sessions on the server, so they can hold more data
+	// and be removed before they expire. See: SessionStore
+	Store SessionStore
+	// GCInterval is how often expired sessions are removed from stores
+	// that have a GC method. Zero turns off automatic garbage
+	// collection. Defaults to ten minutes.
+	GCInterval time.Duration
+
+	gcLock sync.Mutex
+	lastGC time.Time
+}
+
+// SessionStore saves sessions on the server by their ID. Stores that also
+// have a "GC() error" method to remove expired sessions have it called
+// every Sessions.GCInterval. See: MemorySessionStore, FileSessionStore
+type SessionStore interface {
+	// Load returns the session with the given ID, or nil if there is no
+	// session with that ID or it has expired
+	Load(id string) (Session, error)
+	// Save saves the session with the given ID until the given time. A
+	// zero time means the session does not expire.
+	Save(id string, session Session, expires time.Time) error
+	// Delete removes the session with the given ID
+	Delete(id string) error
+}
+
+// sessionCollector is a SessionStore that can remove expired sessions
+type sessionCollector interface {
+	GC() error
+}
+
+// NewSessions creates a new Sessions with the default settings
+func NewSessions() *Sessions {
+	return &Sessions{
+		CookieName: "mojo",
+		Path:       "/",
+		Expiration: time.Hour,
+		HttpOnly:   true,
+		SameSite:   http.SameSiteLaxMode,
+		GCInterval: 10 * time.Minute,
+	}
+}
+
+// sessionData is the contents of a session cookie or a session saved
+// in a FileSessionStore
+type sessionData struct {
+	Data    Session `json:"data"`
+	Expires int64   `json:"expires,omitempty"`
+}
+
+// Session returns the session for the current request, loading it from
+// the session cookie (or the Sessions.Store) the first time it is
+// called. Changes to the session are saved in the response when it is
+// written. Panics if the Application has no Secrets.
+//
+//	c.Session()["user"] = user.Name
+//
+// Cookies with a signature that does not match one of the
+// Application.Secrets are ignored, as are expired cookies. Every
+// response refreshes the session's expiration. See: ExpireSession
+func (c *Context) Session() Session {
+	if c.session == nil {
+		c.session, c.sessionCookie = c.App.Sessions.load(c)
+	}
+	return c.session
+}
+
+// ExpireSession removes all data from the session and tells the client
+// to delete the session cookie. Sessions in a SessionStore are deleted.
+func (c *Context) ExpireSession() {
+	if c.App.Sessions.Store != nil {
+		c.Session()
+	} else if c.session == nil {
+		c.sessionCookie = c.Req.Cookie(c.App.Sessions.CookieName) != nil
+	}
+	c.session = Session{}
+	c.sessionExpired = true
+}
+
+// RegenerateSession gives the session a new ID, keeping its data, and
+// deletes the old ID from the SessionStore. Call it when a user logs in
+// so that a session ID set by someone else before the login cannot be
+// used to take over the session (session fixation). Sessions without a
+// Store have no ID, so this does nothing for them.
+//
+//	c.RegenerateSession()
+//	c.Session()["user"] = user.Name
+func (c *Context) RegenerateSession() {
+	c.Session()
+	if c.sessionID == "" {
+		return
+	}
+	c.App.Sessions.delete(c, c.sessionID)
+	c.sessionID = ""
+}
+
+// storeSession writes the session cookie to the response if the session
+// was used. See: prepareHead
+func (c *Context) storeSession() {
+	if c.session == nil || c.sessionStored {
+		return
+	}
+	c.sessionStored = true
+	c.App.Sessions.store(c)
+}
+
+// load reads and verifies the session cookie. Returns the session data
+// (or an empty session) and true if the request had a session cookie.
+func (s *Sessions) load(c *Context) (Session, bool) {
+	secrets := c.App.Secrets
+	if len(secrets) == 0 {
+		panic("Application.Secrets must be set to use sessions")
+	}
+	cookie := c.Req.Cookie(s.CookieName)
+	if cookie == nil {
+		return Session{}, false
+	}
+
+	// Check the signature with every secret, so that sessions signed with
+	// older secrets are still valid
+	verified := false
+	value := cookie.Value
+	if i := strings.LastIndex(value, "--"); i >= 0 {
+		signature := value[i+2:]
+		value = value[:i]
+		for _, secret := range secrets {
+			if hmac.Equal([]byte(signature), []byte(sign(value, secret))) {
+				verified = true
+				break
+			}
+		}
+	}
+	if !verified {
+		c.App.Log.Debug("Cookie \"%s\" has a bad signature", s.CookieName)
+		return Session{}, true
+	}
+
+	if s.Store != nil {
+		session, err := s.Store.Load(value)
+		if err != nil {
+			c.App.Log.Error("Could not load session: %v", err)
+			return Session{}, true
+		}
+		if session == nil {
+			return Session{}, true
+		}
+		c.sessionID = value
+		return session, true
+	}
+
+	var payload sessionData
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(content, &payload)
+	}
+	if err != nil {
+		c.App.Log.Debug("Cookie \"%s\" could not be decoded: %v", s.CookieName, err)
+		return Session{}, true
+	}
+	if payload.Data == nil || (payload.Expires != 0 && payload.Expires < time.Now().Unix()) {
+		return Session{}, true
+	}
+	return payload.Data, true
+}
+
+// store writes the session cookie, signed with the first secret. Empty
+// and expired sessions delete the cookie.
+func (s *Sessions) store(c *Context) {
+	cookie := &http.Cookie{
+		Name:     s.CookieName,
+		Path:     s.Path,
+		Domain:   s.Domain,
+		Secure:   s.Secure,
+		HttpOnly: s.HttpOnly,
+		SameSite: s.SameSite,
+	}
+	if c.sessionExpired || len(c.session) == 0 {
+		if c.sessionID != "" {
+			s.delete(c, c.sessionID)
+		}
+		if !c.sessionCookie {
+			return
+		}
+		cookie.MaxAge = -1
+		cookie.Expires = time.Unix(1, 0)
+		c.Res.SetCookie(cookie)
+		return
+	}
+
+	if s.Expiration > 0 {
+		cookie.Expires = time.Now().Add(s.Expiration)
+	}
+	var value string
+	if s.Store != nil {
+		if c.sessionID == "" {
+			c.sessionID = newSessionID()
+		}
+		if err := s.Store.Save(c.sessionID, c.session, cookie.Expires); err != nil {
+			c.App.Log.Error("Could not save session: %v", err)
+			return
+		}
+		s.gc(c)
+		value = c.sessionID
+	} else {
+		payload := sessionData{Data: c.session}
+		if !cookie.Expires.IsZero() {
+			payload.Expires = cookie.Expires.Unix()
+		}
+		content, err := json.Marshal(payload)
+		if err != nil {
+			panic(fmt.Sprintf("Could not encode session: %v", err))
+		}
+		value = base64.RawURLEncoding.EncodeToString(content)
+	}
+	cookie.Value = value + "--" + sign(value, c.App.Secrets[0])
+	c.Res.SetCookie(cookie)
+}
+
+// delete deletes the session with the given ID from the store
+func (s *Sessions) delete(c *Context, id string) {
+	if err := s.Store.Delete(id); err != nil {
+		c.App.Log.Error("Could not delete session: %v", err)
+	}
+}
+
+// gc removes expired sessions from the store if it has been at least
+// GCInterval since the last time
+func (s *Sessions) gc(c *Context) {
+	collector, ok := s.Store.(sessionCollector)
+	if !ok || s.GCInterval <= 0 {
+		return
+	}
+	s.gcLock.Lock()
+	defer s.gcLock.Unlock()
+	if time.Since(s.lastGC) < s.GCInterval {
+		return
+	}
+	s.lastGC = time.Now()
+	if err := collector.GC(); err != nil {
+		c.App.Log.Error("Could not remove expired sessions: %v", err)
+	}
+}
+
+// newSessionID returns a new random session ID
+func newSessionID() string {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		panic(fmt.Sprintf("Could not generate session ID: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// sign returns the hex-encoded HMAC-SHA256 signature of the value
+func sign(value string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
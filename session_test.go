@@ -0,0 +1,184 @@
+package mojo_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/preaction/mojo.go"
+)
+
+// sessionRequest runs a request through the app with the given cookie
+// and returns the context
+func sessionRequest(app *mojo.Application, path string, cookie string) *mojo.Context {
+	c := app.BuildContext(mojo.NewRequest("GET", path), nil)
+	if cookie != "" {
+		c.Req.Headers.Add("Cookie", cookie)
+	}
+	app.Handler(c)
+	return c
+}
+
+// sessionCookie returns the session cookie set in the response, if any
+func sessionCookie(c *mojo.Context) *http.Cookie {
+	res := &http.Response{Header: http.Header(c.Res.Headers)}
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == "mojo" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func signCookie(payload string, secret string) string {
+	value := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return "mojo=" + value + "--" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestContextSession(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Log.Level("fatal")
+	app.Secrets = []string{"s3cret"}
+	app.Routes.Get("/login").To(func(c *mojo.Context) {
+		c.Session()["user"] = "fry"
+		c.Res.Text("logged in")
+	})
+	app.Routes.Get("/user").To(func(c *mojo.Context) {
+		user, _ := c.Session()["user"].(string)
+		c.Res.Text(user)
+	})
+	app.Routes.Get("/logout").To(func(c *mojo.Context) {
+		c.ExpireSession()
+		c.Res.Text("logged out")
+	})
+	app.Routes.Get("/none").To(func(c *mojo.Context) {
+		c.Res.Text("no session")
+	})
+
+	c := sessionRequest(app, "/login", "")
+	cookie := sessionCookie(c)
+	if cookie == nil {
+		t.Fatalf("Session cookie not set. Headers: %v", c.Res.Headers)
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" || cookie.Secure {
+		t.Errorf("Wrong cookie attributes: %s", c.Res.Headers.Header("Set-Cookie"))
+	}
+	if until := time.Until(cookie.Expires); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("Wrong cookie expiration: %s", cookie.Expires)
+	}
+	login := "mojo=" + cookie.Value
+
+	c = sessionRequest(app, "/user", login)
+	if body := c.Res.Content.String(); body != "fry" {
+		t.Errorf("Session not loaded from cookie. Got: %q", body)
+	}
+	if sessionCookie(c) == nil {
+		t.Errorf("Session expiration not refreshed")
+	}
+
+	c = sessionRequest(app, "/none", login)
+	if c.Res.Headers.Exists("Set-Cookie") {
+		t.Errorf("Unused session wrote cookie: %v", c.Res.Headers["Set-Cookie"])
+	}
+
+	// Tampered cookies are ignored
+	value := strings.Replace(cookie.Value, cookie.Value[:4], "AAAA", 1)
+	c = sessionRequest(app, "/user", "mojo="+value)
+	if body := c.Res.Content.String(); body != "" {
+		t.Errorf("Tampered session loaded. Got: %q", body)
+	}
+	c = sessionRequest(app, "/user", "mojo=garbage")
+	if body := c.Res.Content.String(); body != "" {
+		t.Errorf("Unsigned session loaded. Got: %q", body)
+	}
+
+	// Expired sessions are ignored
+	expired := signCookie(fmt.Sprintf(`{"data":{"user":"fry"},"expires":%d}`, time.Now().Add(-time.Minute).Unix()), "s3cret")
+	c = sessionRequest(app, "/user", expired)
+	if body := c.Res.Content.String(); body != "" {
+		t.Errorf("Expired session loaded. Got: %q", body)
+	}
+
+	// Expiring the session deletes the cookie
+	c = sessionRequest(app, "/logout", login)
+	if cookie := sessionCookie(c); cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("Session cookie not deleted: %v", c.Res.Headers["Set-Cookie"])
+	}
+	c = sessionRequest(app, "/logout", "")
+	if c.Res.Headers.Exists("Set-Cookie") {
+		t.Errorf("Deleted missing session cookie: %v", c.Res.Headers["Set-Cookie"])
+	}
+}
+
+func TestContextSessionSecretRotation(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Log.Level("fatal")
+	app.Secrets = []string{"old"}
+	app.Routes.Get("/user").To(func(c *mojo.Context) {
+		user, _ := c.Session()["user"].(string)
+		c.Res.Text(user)
+	})
+	login := signCookie(`{"data":{"user":"fry"}}`, "old")
+
+	// Cookies signed with older secrets are still valid, and are signed
+	// again with the newest secret
+	app.Secrets = []string{"new", "old"}
+	c := sessionRequest(app, "/user", login)
+	if body := c.Res.Content.String(); body != "fry" {
+		t.Errorf("Session signed with old secret not loaded. Got: %q", body)
+	}
+	cookie := sessionCookie(c)
+	if cookie == nil {
+		t.Fatalf("Session cookie not set")
+	}
+
+	app.Secrets = []string{"new"}
+	c = sessionRequest(app, "/user", "mojo="+cookie.Value)
+	if body := c.Res.Content.String(); body != "fry" {
+		t.Errorf("Session not signed with new secret. Got: %q", body)
+	}
+	c = sessionRequest(app, "/user", login)
+	if body := c.Res.Content.String(); body != "" {
+		t.Errorf("Session signed with removed secret loaded. Got: %q", body)
+	}
+}
+
+func TestContextSessionCookieAttributes(t *testing.T) {
+	app := mojo.NewApplication()
+	app.Log.Level("fatal")
+	app.Sessions.CookieName = "app"
+	app.Sessions.Path = "/app"
+	app.Sessions.Domain = "example.com"
+	app.Sessions.Expiration = 0
+	app.Sessions.Secure = true
+	app.Sessions.SameSite = http.SameSiteStrictMode
+	app.Routes.Get("/").To(func(c *mojo.Context) {
+		c.Session()["user"] = "fry"
+	})
+
+	// Sessions need secrets
+	c := sessionRequest(app, "/", "")
+	if c.Res.Code != 500 {
+		t.Errorf("Session without secrets did not fail. Got: %d", c.Res.Code)
+	}
+
+	app.Secrets = []string{"s3cret"}
+	c = sessionRequest(app, "/", "")
+	header := c.Res.Headers.Header("Set-Cookie")
+	for _, attr := range []string{"app=", "Path=/app", "Domain=example.com", "Secure", "HttpOnly", "SameSite=Strict"} {
+		if !strings.Contains(header, attr) {
+			t.Errorf("Set-Cookie missing %s. Got: %s", attr, header)
+		}
+	}
+	if strings.Contains(header, "Expires") {
+		t.Errorf("Session cookie has expiration. Got: %s", header)
+	}
+}
@@ -0,0 +1,214 @@
+package mojo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemorySessionStore is a SessionStore that keeps sessions in memory.
+// Sessions are lost when the application restarts, and are not shared
+// between processes.
+type MemorySessionStore struct {
+	lock     sync.Mutex
+	sessions map[string]memorySession
+}
+
+// memorySession is a session saved in a MemorySessionStore. The data is
+// kept as JSON so handlers never share the session's maps.
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemorySessionStore creates a new, empty MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]memorySession{}}
+}
+
+// Load returns the session with the given ID, or nil if there is no
+// session with that ID or it has expired
+func (store *MemorySessionStore) Load(id string) (Session, error) {
+	store.lock.Lock()
+	saved, ok := store.sessions[id]
+	store.lock.Unlock()
+	if !ok || saved.expired(time.Now()) {
+		return nil, nil
+	}
+	session := Session{}
+	if err := json.Unmarshal(saved.data, &session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Save saves the session with the given ID until the given time
+func (store *MemorySessionStore) Save(id string, session Session, expires time.Time) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.sessions == nil {
+		store.sessions = map[string]memorySession{}
+	}
+	store.sessions[id] = memorySession{data: data, expires: expires}
+	return nil
+}
+
+// Delete removes the session with the given ID
+func (store *MemorySessionStore) Delete(id string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	delete(store.sessions, id)
+	return nil
+}
+
+// GC removes expired sessions
+func (store *MemorySessionStore) GC() error {
+	now := time.Now()
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for id, saved := range store.sessions {
+		if saved.expired(now) {
+			delete(store.sessions, id)
+		}
+	}
+	return nil
+}
+
+// expired returns true if the session expired before the given time
+func (saved memorySession) expired(now time.Time) bool {
+	return !saved.expires.IsZero() && saved.expires.Before(now)
+}
+
+// FileSessionStore is a SessionStore that keeps each session in a JSON
+// file in a directory. The directory is created if it does not exist.
+//
+//	app.Sessions.Store = mojo.NewFileSessionStore(app.Home.Child("sessions"))
+type FileSessionStore struct {
+	Dir File
+}
+
+// NewFileSessionStore creates a new FileSessionStore for the given
+// directory
+func NewFileSessionStore(dir File) *FileSessionStore {
+	return &FileSessionStore{Dir: dir}
+}
+
+// file returns the file for the given session ID. Returns an error if
+// the ID is not safe to use in a file name.
+func (store *FileSessionStore) file(id string) (File, error) {
+	if id == "" || strings.Trim(id, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+		return File{}, fmt.Errorf("invalid session ID %q", id)
+	}
+	return NewFile(store.Dir.String(), id+".json"), nil
+}
+
+// Load returns the session with the given ID, or nil if there is no
+// session with that ID or it has expired
+func (store *FileSessionStore) Load(id string) (Session, error) {
+	file, err := store.file(id)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := readSessionFile(file)
+	if err != nil || saved == nil {
+		return nil, err
+	}
+	if saved.Expires != 0 && saved.Expires < time.Now().Unix() {
+		return nil, nil
+	}
+	return saved.Data, nil
+}
+
+// Save saves the session with the given ID until the given time
+func (store *FileSessionStore) Save(id string, session Session, expires time.Time) error {
+	file, err := store.file(id)
+	if err != nil {
+		return err
+	}
+	saved := sessionData{Data: session}
+	if !expires.IsZero() {
+		saved.Expires = expires.Unix()
+	}
+	content, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(store.Dir.String(), 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that other requests never read
+	// a partial session
+	tmp, err := os.CreateTemp(store.Dir.String(), id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), file.String())
+}
+
+// Delete removes the session with the given ID
+func (store *FileSessionStore) Delete(id string) error {
+	file, err := store.file(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file.String()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GC removes expired sessions
+func (store *FileSessionStore) GC() error {
+	entries, err := os.ReadDir(store.Dir.String())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		file := NewFile(store.Dir.String(), entry.Name())
+		saved, err := readSessionFile(file)
+		if err != nil || saved == nil || saved.Expires == 0 || saved.Expires >= now {
+			continue
+		}
+		if err := os.Remove(file.String()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSessionFile reads a session saved by a FileSessionStore. Returns
+// nil if the file does not exist.
+func readSessionFile(file File) (*sessionData, error) {
+	content, err := os.ReadFile(file.String())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	saved := &sessionData{}
+	if err := json.Unmarshal(content, saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
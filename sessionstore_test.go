@@ -0,0 +1,178 @@
+package mojo_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/preaction/mojo.go"
+)
+
+func TestSessionStores(t *testing.T) {
+	stores := map[string]mojo.SessionStore{
+		"memory": mojo.NewMemorySessionStore(),
+		"file":   mojo.NewFileSessionStore(mojo.NewFile(t.TempDir(), "sessions")),
+	}
+	for name, store := range stores {
+		session, err := store.Load("missing")
+		if session != nil || err != nil {
+			t.Errorf("%s: Load missing session: %v, %v", name, session, err)
+		}
+
+		if err := store.Save("fry", mojo.Session{"user": "fry", "visits": 1}, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("%s: Save: %v", name, err)
+		}
+		session, err = store.Load("fry")
+		if err != nil || session["user"] != "fry" || session["visits"] != 1.0 {
+			t.Errorf("%s: Load: %v, %v", name, session, err)
+		}
+
+		if err := store.Save("leela", mojo.Session{"user": "leela"}, time.Time{}); err != nil {
+			t.Fatalf("%s: Save without expiration: %v", name, err)
+		}
+		if session, _ := store.Load("leela"); session["user"] != "leela" {
+			t.Errorf("%s: Load session without expiration: %v", name, session)
+		}
+
+		if err := store.Save("bender", mojo.Session{"user": "bender"}, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("%s: Save expired: %v", name, err)
+		}
+		if session, _ := store.Load("bender"); session != nil {
+			t.Errorf("%s: Loaded expired session: %v", name, session)
+		}
+
+		if err := store.Delete("fry"); err != nil {
+			t.Errorf("%s: Delete: %v", name, err)
+		}
+		if session, _ := store.Load("fry"); session != nil {
+			t.Errorf("%s: Loaded deleted session: %v", name, session)
+		}
+		if err := store.Delete("fry"); err != nil {
+			t.Errorf("%s: Delete missing session: %v", name, err)
+		}
+	}
+}
+
+func TestFileSessionStoreGC(t *testing.T) {
+	dir := mojo.NewFile(t.TempDir())
+	store := mojo.NewFileSessionStore(dir)
+	store.Save("expired", mojo.Session{"user": "bender"}, time.Now().Add(-time.Hour))
+	store.Save("current", mojo.Session{"user": "fry"}, time.Now().Add(time.Hour))
+	store.Save("forever", mojo.Session{"user": "leela"}, time.Time{})
+
+	if err := store.GC(); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	for name, exists := range map[string]bool{"expired": false, "current": true, "forever": true} {
+		_, err := os.Stat(dir.Child(name + ".json").String())
+		if exists != (err == nil) {
+			t.Errorf("After GC, %s exists: %v; Expect: %v", name, err == nil, exists)
+		}
+	}
+
+	if _, err := store.Load("../current"); err == nil {
+		t.Errorf("Loaded session with invalid ID")
+	}
+}
+
+func TestContextSessionStore(t *testing.T) {
+	store := mojo.NewMemorySessionStore()
+	app := mojo.NewApplication()
+	app.Log.Level("fatal")
+	app.Secrets = []string{"s3cret"}
+	app.Sessions.Store = store
+	app.Routes.Get("/visit").To(func(c *mojo.Context) {
+		c.Session()["visits"] = strings.Repeat("x", 8192)
+	})
+	app.Routes.Get("/login").To(func(c *mojo.Context) {
+		c.RegenerateSession()
+		c.Session()["user"] = "fry"
+	})
+	app.Routes.Get("/user").To(func(c *mojo.Context) {
+		user, _ := c.Session()["user"].(string)
+		c.Res.Text(user)
+	})
+	app.Routes.Get("/logout").To(func(c *mojo.Context) {
+		c.ExpireSession()
+	})
+
+	// The cookie holds the session ID, not the session data
+	c := sessionRequest(app, "/visit", "")
+	cookie := sessionCookie(c)
+	if cookie == nil {
+		t.Fatalf("Session cookie not set")
+	}
+	if len(cookie.Value) > 200 {
+		t.Errorf("Session data stored in cookie: %d bytes", len(cookie.Value))
+	}
+	id := cookie.Value[:strings.LastIndex(cookie.Value, "--")]
+	if session, _ := store.Load(id); session == nil {
+		t.Errorf("Session not saved in store")
+	}
+	visitor := "mojo=" + cookie.Value
+
+	// Logging in gives the session a new ID and removes the old one
+	c = sessionRequest(app, "/login", visitor)
+	login := sessionCookie(c)
+	if login == nil || login.Value == cookie.Value {
+		t.Fatalf("Session ID not regenerated: %v", login)
+	}
+	if session, _ := store.Load(id); session != nil {
+		t.Errorf("Old session ID still in store")
+	}
+	newID := login.Value[:strings.LastIndex(login.Value, "--")]
+	if session, _ := store.Load(newID); session["user"] != "fry" || session["visits"] == nil {
+		t.Errorf("Session data not kept when regenerating ID: %v", session)
+	}
+
+	c = sessionRequest(app, "/user", visitor)
+	if body := c.Res.Content.String(); body != "" {
+		t.Errorf("Old session ID still valid. Got: %q", body)
+	}
+	c = sessionRequest(app, "/user", "mojo="+login.Value)
+	if body := c.Res.Content.String(); body != "fry" {
+		t.Errorf("Session not loaded from store. Got: %q", body)
+	}
+
+	// Unsigned session IDs are ignored
+	c = sessionRequest(app, "/user", "mojo="+newID)
+	if body := c.Res.Content.String(); body != "" {
+		t.Errorf("Unsigned session ID loaded. Got: %q", body)
+	}
+
+	// Logging out deletes the session from the store
+	c = sessionRequest(app, "/logout", "mojo="+login.Value)
+	if cookie := sessionCookie(c); cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("Session cookie not deleted: %v", c.Res.Headers["Set-Cookie"])
+	}
+	if session, _ := store.Load(newID); session != nil {
+		t.Errorf("Session not deleted from store: %v", session)
+	}
+}
+
+func TestContextSessionStoreGC(t *testing.T) {
+	dir := mojo.NewFile(t.TempDir())
+	store := mojo.NewFileSessionStore(dir)
+	store.Save("expired", mojo.Session{"user": "bender"}, time.Now().Add(-time.Hour))
+
+	app := mojo.NewApplication()
+	app.Log.Level("fatal")
+	app.Secrets = []string{"s3cret"}
+	app.Sessions.Store = store
+	app.Routes.Get("/").To(func(c *mojo.Context) {
+		c.Session()["user"] = "fry"
+	})
+
+	sessionRequest(app, "/", "")
+	if _, err := os.Stat(dir.Child("expired.json").String()); err == nil {
+		t.Errorf("Expired session not removed")
+	}
+
+	// Garbage collection runs once every GCInterval
+	store.Save("expired", mojo.Session{"user": "bender"}, time.Now().Add(-time.Hour))
+	sessionRequest(app, "/", "")
+	if _, err := os.Stat(dir.Child("expired.json").String()); err != nil {
+		t.Errorf("Expired sessions removed before GCInterval")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/preaction/mojo.go/util"
@@ -22,8 +23,13 @@ func init() {
 
 // Static handles requests for static files, including support for Range and HTTP
 // caching (If-Modified-Since and If-None-Match).
+//
+// In "development" mode, static files are sent with "Cache-Control:
+// no-cache" so changes are seen right away. In other modes, they are sent
+// with a "Cache-Control: max-age" of MaxAge, if it is set.
 type Static struct {
-	Paths []fs.FS
+	Paths  []fs.FS
+	MaxAge time.Duration
 }
 
 // AddPath adds a path to look up files.
@@ -34,8 +40,13 @@ func (st *Static) AddPath(f File) {
 // Dispatch tries to find a static file to handle the request. Returns
 // true if a static file was found and served.
 func (st *Static) Dispatch(c *Context) bool {
-	// Remove the leading "/"
-	path := c.Req.URL.Path[1:]
+	// Remove the leading "/". Use the stash path so that mounted
+	// applications find their files.
+	path := c.Req.URL.Path
+	if stashPath, ok := c.Stash["path"].(string); ok {
+		path = stashPath
+	}
+	path = strings.TrimPrefix(path, "/")
 	if path == "" {
 		return false
 	}
@@ -65,6 +76,12 @@ func (st *Static) Serve(c *Context, path string) bool {
 		return false
 	}
 
+	if c.App != nil && c.App.Mode == "development" {
+		c.Res.Headers.Add("Cache-Control", "no-cache")
+	} else if st.MaxAge > 0 {
+		c.Res.Headers.Add("Cache-Control", fmt.Sprintf("max-age=%d", int(st.MaxAge.Seconds())))
+	}
+
 	// Handle If-None-Match/If-Modified-Since
 	if c.Req.Headers.Exists("If-None-Match") || c.Req.Headers.Exists("If-Modified-Since") {
 		fstat, err := file.Stat()
@@ -93,20 +110,30 @@ func (st *Static) Serve(c *Context, path string) bool {
 		c.Res.Headers.Add("Etag", fmt.Sprintf("\"%s\"", etag))
 	}
 	// XXX: Move mojo.File to mojo.Path and start passing them around as
-	// paths instead of strings...
+	// paths instead of strings.
 	if ext := filepath.Ext(path); ext != "" {
-		// ... because Go's idea of a file extension includes the "."
-		if t, ok := Types[ext[1:len(ext)]]; ok {
-			c.Res.Headers.Add("Content-Type", t[0])
+		if t := c.types().Type(ext); t != "" {
+			c.Res.Headers.Add("Content-Type", t)
 		}
 	}
+	if !c.Res.Headers.Exists("Content-Type") {
+		c.Res.Headers.Add("Content-Type", "application/octet-stream")
+	}
 
 	// Handle Range request
-	if c.Req.Headers.Exists("Range") {
+	if c.Req.Headers.Exists("Range") && err == nil {
 		start, end := c.Req.Headers.Range()
-		// XXX: Validate ranges else return 416
+		size := fstat.Size()
+		if start >= size || (start < 0 && end <= 0) || (end >= 0 && end < start) {
+			c.Res.Code = 416
+			c.Res.Headers.Add("Content-Range", fmt.Sprintf("bytes */%d", size))
+			c.Res.Content = NewAsset("")
+			return true
+		}
 		c.Res.Code = 206
 		c.Res.Content.Range(start, end)
+		start, end = resolveRange(start, end, size)
+		c.Res.Headers.Add("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
 		return true
 	}
 
@@ -46,8 +46,8 @@ func TestStatic(t *testing.T) {
 		t.Errorf("Static dispatch sent incorrect Etag. Got: %s, Expect: %s", c.Res.Headers.Etag(), etag)
 	}
 
-	if c.Res.Headers.Header("Content-Type") != mojo.Types["txt"][0] {
-		t.Errorf("Static dispatch sent incorrect Content-Type. Got: %s, Expect: %s", c.Res.Headers.Header("Content-Type"), mojo.Types["txt"][0])
+	if c.Res.Headers.Header("Content-Type") != mojo.DefaultTypes.Type("txt") {
+		t.Errorf("Static dispatch sent incorrect Content-Type. Got: %s, Expect: %s", c.Res.Headers.Header("Content-Type"), mojo.DefaultTypes.Type("txt"))
 	}
 }
 
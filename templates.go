@@ -0,0 +1,118 @@
+package mojo
+
+// defaultTemplates are the built-in templates used by GoRenderer when a
+// template is not found in the cache or template paths. Add a template
+// with the same name to override them.
+var defaultTemplates = map[string]string{
+	"exception.html.tmpl": exceptionTemplate,
+	"not_found.html.tmpl": notFoundTemplate,
+}
+
+// exceptionTemplate shows the details of an exception in development
+// mode, and a plain error page in other modes
+const exceptionTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<% if eq .App.Mode "development" -%>
+<title>Server error (development mode)</title>
+<style>
+body { font-family: sans-serif; margin: 0; background: #f5f5f5; color: #333 }
+h1 { background: #a00; color: #fff; margin: 0; padding: 1em; font-size: 1.2em }
+section { background: #fff; margin: 1em; padding: 1em; box-shadow: 0 1px 3px #999 }
+h2 { font-size: 1em; margin-top: 0 }
+pre, td { font-family: monospace; font-size: 0.9em }
+table { border-collapse: collapse }
+td { padding: 0.1em 0.5em; vertical-align: top }
+tr.current { background: #fdd; font-weight: bold }
+td.key { color: #777; text-align: right }
+</style>
+<% else -%>
+<title>Server error (500)</title>
+<% end -%>
+</head>
+<body>
+<% if eq .App.Mode "development" -%>
+<% with .Stash.exception -%>
+<h1><% .Message %></h1>
+<section id="source">
+<h2><% .File %> line <% .Line %> (<% .Func %>)</h2>
+<table>
+<% range .Source -%>
+<tr<% if .Current %> class="current"<% end %>><td class="key"><% .Number %></td><td><pre><% .Text %></pre></td></tr>
+<% end -%>
+</table>
+</section>
+<% end -%>
+<section id="request">
+<h2><% .Req.Method %> <% .Req.URL %></h2>
+<table>
+<% range $name, $values := .Req.Headers -%>
+<% range $values %><tr><td class="key"><% $name %></td><td><% . %></td></tr><% end %>
+<% end -%>
+</table>
+</section>
+<section id="stash">
+<h2>Stash</h2>
+<table>
+<% range $key, $value := .Stash -%>
+<% if ne $key "exception" %><tr><td class="key"><% $key %></td><td><pre><% printf "%#v" $value %></pre></td></tr><% end %>
+<% end -%>
+</table>
+</section>
+<% with .Stash.exception -%>
+<section id="stack">
+<h2>Stack</h2>
+<pre><% .Stack %></pre>
+</section>
+<% end -%>
+<% else -%>
+<h1>Internal Server Error</h1>
+<p>The server encountered an error and could not complete your request.</p>
+<% end -%>
+</body>
+</html>
+`
+
+// notFoundTemplate shows the request that was not found in development
+// mode, and a plain error page in other modes
+const notFoundTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<% if eq .App.Mode "development" -%>
+<title><% if eq .Res.Code 405 %>Method not allowed<% else %>Page not found<% end %> (development mode)</title>
+<style>
+body { font-family: sans-serif; margin: 0; background: #f5f5f5; color: #333 }
+h1 { background: #555; color: #fff; margin: 0; padding: 1em; font-size: 1.2em }
+section { background: #fff; margin: 1em; padding: 1em; box-shadow: 0 1px 3px #999 }
+</style>
+<% else -%>
+<title><% if eq .Res.Code 405 %>Method not allowed<% else %>Page not found<% end %> (<% .Res.Code %>)</title>
+<% end -%>
+</head>
+<body>
+<% if eq .App.Mode "development" -%>
+<% if eq .Res.Code 405 -%>
+<h1>Method not allowed</h1>
+<section id="request">
+<p>No route matched <code><% .Req.Method %> <% .Stash.path %></code>.</p>
+<p>Allowed methods: <code><% .Res.Headers.Header "Allow" %></code></p>
+</section>
+<% else -%>
+<h1>Page not found</h1>
+<section id="request">
+<p>No static file or route matched <code><% .Req.Method %> <% .Stash.path %></code>.</p>
+<p>Run the "routes" command to see the available routes.</p>
+</section>
+<% end -%>
+<% else -%>
+<% if eq .Res.Code 405 -%>
+<h1>Method not allowed</h1>
+<p>The page you requested does not allow this method.</p>
+<% else -%>
+<h1>Page not found</h1>
+<p>The page you requested could not be found.</p>
+<% end -%>
+<% end -%>
+</body>
+</html>
+`
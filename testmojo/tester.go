@@ -6,8 +6,11 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/preaction/mojo.go"
 )
@@ -93,7 +96,7 @@ func ReadHTTPResponse(t *testing.T, c *mojo.Context) (*httptest.ResponseRecorder
 // request is completed without panicking.
 func (t *Tester) GetOk(path string, name ...string) *Tester {
 	t.T.Helper()
-	fillName(&name, fmt.Sprintf("GET %d", path))
+	fillName(&name, fmt.Sprintf("GET %s", path))
 
 	// XXX: Create a server to integration test (and in case we want to
 	// turn Application and Server into interfaces in the future)
@@ -153,6 +156,90 @@ func (t *Tester) TextIs(text string, name ...string) *Tester {
 	return t
 }
 
+// BodyIs tests the body sent to the client for the current request
+// equals the given text. Unlike TextIs, this includes content streamed
+// with Context.Write and Context.WriteChunk.
+func (t *Tester) BodyIs(text string, name ...string) *Tester {
+	t.T.Helper()
+	fillName(&name, "Response body")
+	if !t.hasRes(name) {
+		return t
+	}
+
+	res, ok := t.Context.Res.Writer.(*httptest.ResponseRecorder)
+	if !ok {
+		t.errorf(name, "Response was not recorded")
+		return t
+	}
+	if res.Body.String() != text {
+		t.errorf(name, "Body is not equal:\n\tExpect: %s\n\tGot: %s", text, res.Body.String())
+		return t
+	}
+	t.Success = true
+	return t
+}
+
+// EventsAre tests the Server-Sent Events sent to the client for the
+// current request equal the given events. See: ParseEvents
+func (t *Tester) EventsAre(events []mojo.Event, name ...string) *Tester {
+	t.T.Helper()
+	fillName(&name, "Events")
+	if !t.hasRes(name) {
+		return t
+	}
+
+	res, ok := t.Context.Res.Writer.(*httptest.ResponseRecorder)
+	if !ok {
+		t.errorf(name, "Response was not recorded")
+		return t
+	}
+	if ct := res.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.errorf(name, "Content-Type is not text/event-stream: %s", ct)
+		return t
+	}
+	got := ParseEvents(res.Body.String())
+	if !reflect.DeepEqual(got, events) {
+		t.errorf(name, "Events are not equal:\n\tExpect: %+v\n\tGot: %+v", events, got)
+		return t
+	}
+	t.Success = true
+	return t
+}
+
+// ParseEvents parses the given text/event-stream body into Events.
+// Comments and unknown fields are ignored.
+func ParseEvents(body string) []mojo.Event {
+	events := []mojo.Event{}
+	for _, block := range strings.Split(body, "\n\n") {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+		event := mojo.Event{}
+		data := []string{}
+		for _, line := range strings.Split(block, "\n") {
+			field, value := line, ""
+			if colon := strings.Index(line, ":"); colon >= 0 {
+				field, value = line[:colon], strings.TrimPrefix(line[colon+1:], " ")
+			}
+			switch field {
+			case "id":
+				event.ID = value
+			case "event":
+				event.Event = value
+			case "data":
+				data = append(data, value)
+			case "retry":
+				if ms, err := strconv.Atoi(value); err == nil {
+					event.Retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+		event.Data = strings.Join(data, "\n")
+		events = append(events, event)
+	}
+	return events
+}
+
 // hasRes returns true if there is a current request to test, updating
 // the Success flag if not
 func (t *Tester) hasRes(name []string) bool {
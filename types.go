@@ -1,70 +1,240 @@
 package mojo
 
-// Types is a mapping of format names (or file extensions) to MIME types
-// suitable for the HTTP Content-Type and Accept headers.
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Types is a registry of format names (or file extensions) and their MIME
+// types suitable for the HTTP Content-Type and Accept headers.
 //
 // This list is taken from https://docs.mojolicious.org/Mojolicious/Types#DESCRIPTION.
-// The first item in the array is the canonical one to use in
-// Content-Type headers. The other items are aliases that we may find in
-// requests.
+// The first MIME type registered for a format is the canonical one to use
+// in Content-Type headers. The other types are aliases that we may find
+// in requests.
 //
 // The most common types are already defined.
 //
-//   appcache -> text/cache-manifest
-//   atom     -> application/atom+xml
-//   bin      -> application/octet-stream
-//   css      -> text/css
-//   gif      -> image/gif
-//   gz       -> application/x-gzip
-//   htm      -> text/html
-//   html     -> text/html;charset=UTF-8
-//   ico      -> image/x-icon
-//   jpeg     -> image/jpeg
-//   jpg      -> image/jpeg
-//   js       -> application/javascript
-//   json     -> application/json;charset=UTF-8
-//   mp3      -> audio/mpeg
-//   mp4      -> video/mp4
-//   ogg      -> audio/ogg
-//   ogv      -> video/ogg
-//   pdf      -> application/pdf
-//   png      -> image/png
-//   rss      -> application/rss+xml
-//   svg      -> image/svg+xml
-//   ttf      -> font/ttf
-//   txt      -> text/plain;charset=UTF-8
-//   webm     -> video/webm
-//   woff     -> font/woff
-//   woff2    -> font/woff2
-//   xml      -> application/xml,text/xml
-//   zip      -> application/zip
-var Types = map[string][]string{
-	"appcache": []string{"text/cache-manifest"},
-	"atom":     []string{"application/atom+xml"},
-	"bin":      []string{"application/octet-stream"},
-	"css":      []string{"text/css"},
-	"gif":      []string{"image/gif"},
-	"gz":       []string{"application/x-gzip"},
-	"htm":      []string{"text/html"},
-	"html":     []string{"text/html;charset=UTF-8"},
-	"ico":      []string{"image/x-icon"},
-	"jpeg":     []string{"image/jpeg"},
-	"jpg":      []string{"image/jpeg"},
-	"js":       []string{"application/javascript"},
-	"json":     []string{"application/json;charset=UTF-8"},
-	"mp3":      []string{"audio/mpeg"},
-	"mp4":      []string{"video/mp4"},
-	"ogg":      []string{"audio/ogg"},
-	"ogv":      []string{"video/ogg"},
-	"pdf":      []string{"application/pdf"},
-	"png":      []string{"image/png"},
-	"rss":      []string{"application/rss+xml"},
-	"svg":      []string{"image/svg+xml"},
-	"ttf":      []string{"font/ttf"},
-	"txt":      []string{"text/plain;charset=UTF-8"},
-	"webm":     []string{"video/webm"},
-	"woff":     []string{"font/woff"},
-	"woff2":    []string{"font/woff2"},
-	"xml":      []string{"application/xml", "text/xml"},
-	"zip":      []string{"application/zip"},
+//	appcache -> text/cache-manifest
+//	atom     -> application/atom+xml
+//	bin      -> application/octet-stream
+//	css      -> text/css
+//	gif      -> image/gif
+//	gz       -> application/x-gzip
+//	htm      -> text/html
+//	html     -> text/html;charset=UTF-8
+//	ico      -> image/x-icon
+//	jpeg     -> image/jpeg
+//	jpg      -> image/jpeg
+//	js       -> application/javascript
+//	json     -> application/json;charset=UTF-8
+//	mp3      -> audio/mpeg
+//	mp4      -> video/mp4
+//	ogg      -> audio/ogg
+//	ogv      -> video/ogg
+//	pdf      -> application/pdf
+//	png      -> image/png
+//	rss      -> application/rss+xml
+//	svg      -> image/svg+xml
+//	ttf      -> font/ttf
+//	txt      -> text/plain;charset=UTF-8
+//	webm     -> video/webm
+//	woff     -> font/woff
+//	woff2    -> font/woff2
+//	xml      -> application/xml,text/xml
+//	zip      -> application/zip
+type Types struct {
+	types map[string][]string
+}
+
+// DefaultTypes is the registry used when there is no Application, like in
+// the Response helpers. Each Application gets its own copy in
+// Application.Types.
+var DefaultTypes = NewTypes()
+
+// NewTypes creates a new Types registry with the default types
+func NewTypes() *Types {
+	return &Types{types: map[string][]string{
+		"appcache": {"text/cache-manifest"},
+		"atom":     {"application/atom+xml"},
+		"bin":      {"application/octet-stream"},
+		"css":      {"text/css"},
+		"gif":      {"image/gif"},
+		"gz":       {"application/x-gzip"},
+		"htm":      {"text/html"},
+		"html":     {"text/html;charset=UTF-8"},
+		"ico":      {"image/x-icon"},
+		"jpeg":     {"image/jpeg"},
+		"jpg":      {"image/jpeg"},
+		"js":       {"application/javascript"},
+		"json":     {"application/json;charset=UTF-8"},
+		"mp3":      {"audio/mpeg"},
+		"mp4":      {"video/mp4"},
+		"ogg":      {"audio/ogg"},
+		"ogv":      {"video/ogg"},
+		"pdf":      {"application/pdf"},
+		"png":      {"image/png"},
+		"rss":      {"application/rss+xml"},
+		"svg":      {"image/svg+xml"},
+		"ttf":      {"font/ttf"},
+		"txt":      {"text/plain;charset=UTF-8"},
+		"webm":     {"video/webm"},
+		"woff":     {"font/woff"},
+		"woff2":    {"font/woff2"},
+		"xml":      {"application/xml", "text/xml"},
+		"zip":      {"application/zip"},
+	}}
+}
+
+// Type returns the canonical MIME type for the given format or file
+// extension (with or without the leading "."), or "" if the format is
+// not registered.
+func (t *Types) Type(ext string) string {
+	types := t.types[strings.ToLower(strings.TrimPrefix(ext, "."))]
+	if len(types) == 0 {
+		return ""
+	}
+	return types[0]
+}
+
+// mediaType returns the canonical MIME type for the given format without
+// any parameters, like "application/json" for "json"
+func (t *Types) mediaType(ext string) string {
+	return strings.TrimSpace(strings.Split(t.Type(ext), ";")[0])
+}
+
+// Register sets the MIME types for the given format, replacing any
+// existing types. The first type is the canonical one for Content-Type
+// headers.
+//
+//	app.Types.Register("md", "text/markdown;charset=UTF-8", "text/x-markdown")
+func (t *Types) Register(ext string, types ...string) {
+	if len(types) == 0 {
+		panic(fmt.Sprintf("Types.Register: No MIME types given for format %s", ext))
+	}
+	if t.types == nil {
+		t.types = map[string][]string{}
+	}
+	t.types[strings.ToLower(ext)] = types
+}
+
+// Alias adds more MIME types to recognize for the given format, without
+// changing its canonical type.
+//
+//	app.Types.Alias("json", "text/json")
+func (t *Types) Alias(ext string, types ...string) {
+	ext = strings.ToLower(ext)
+	if _, ok := t.types[ext]; !ok {
+		panic(fmt.Sprintf("Types.Alias: Unknown format %s", ext))
+	}
+	t.types[ext] = append(t.types[ext], types...)
+}
+
+// Detect returns the formats for the given Accept or Content-Type header,
+// in order of preference. Media ranges are ordered by their q-value, then
+// by how specific they are ("text/html" before "text/*"), then by their
+// order in the header. Ranges with q=0 and the "*/*" range are ignored.
+// Parameters like "charset" are ignored when matching, so
+// "application/json" matches "application/json;charset=UTF-8".
+func (t *Types) Detect(accept string) []string {
+	return t.detect(accept, "")
+}
+
+// detect returns the formats for the given Accept header, like Detect.
+// The "*/*" range becomes the given wildcard format in its place in the
+// order of preference, or is ignored if wildcard is empty.
+func (t *Types) detect(accept string, wildcard string) []string {
+	names := make([]string, 0, len(t.types))
+	for name := range t.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	formats := []string{}
+	seen := map[string]bool{}
+	for _, r := range parseAccept(accept) {
+		if r.media == "*/*" {
+			if wildcard != "" && !seen[wildcard] {
+				formats = append(formats, wildcard)
+				seen[wildcard] = true
+			}
+			continue
+		}
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			for _, mime := range t.types[name] {
+				if matchesMedia(mime, r.media) {
+					formats = append(formats, name)
+					seen[name] = true
+					break
+				}
+			}
+		}
+	}
+	return formats
+}
+
+// acceptRange is a media range from an Accept header
+type acceptRange struct {
+	media string
+	q     float64
+}
+
+// specificity returns 0 for "*/*", 1 for ranges like "text/*", and 2 for
+// full MIME types
+func (r acceptRange) specificity() int {
+	switch {
+	case r.media == "*/*":
+		return 0
+	case strings.HasSuffix(r.media, "/*"):
+		return 1
+	}
+	return 2
+}
+
+// parseAccept parses the given Accept header into media ranges, ordered
+// by q-value and specificity. Ranges with q=0 are left out.
+func parseAccept(header string) []acceptRange {
+	ranges := []acceptRange{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		media := strings.ToLower(strings.TrimSpace(params[0]))
+		if media == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{media: media, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// matchesMedia returns true if the given MIME type (with or without
+// parameters) matches the given media range, which can be a wildcard
+// like "text/*" or "*/*"
+func matchesMedia(mime string, media string) bool {
+	mime = strings.ToLower(strings.TrimSpace(strings.Split(mime, ";")[0]))
+	if media == "*/*" || media == mime {
+		return true
+	}
+	return strings.HasSuffix(media, "/*") && strings.HasPrefix(mime, strings.TrimSuffix(media, "*"))
 }
@@ -0,0 +1,61 @@
+package mojo_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/preaction/mojo.go"
+)
+
+func TestTypesType(t *testing.T) {
+	types := mojo.NewTypes()
+	if got := types.Type("json"); got != "application/json;charset=UTF-8" {
+		t.Errorf("Type(json): %q", got)
+	}
+	if got := types.Type(".PNG"); got != "image/png" {
+		t.Errorf("Type(.PNG): %q", got)
+	}
+	if got := types.Type("unknown"); got != "" {
+		t.Errorf("Type(unknown): %q", got)
+	}
+
+	types.Register("md", "text/markdown;charset=UTF-8", "text/x-markdown")
+	if got := types.Type("md"); got != "text/markdown;charset=UTF-8" {
+		t.Errorf("Type(md) after Register: %q", got)
+	}
+	types.Alias("json", "text/json")
+	if got := types.Type("json"); got != "application/json;charset=UTF-8" {
+		t.Errorf("Type(json) after Alias: %q", got)
+	}
+	if mojo.DefaultTypes.Type("md") != "" {
+		t.Errorf("Register changed DefaultTypes")
+	}
+}
+
+func TestTypesDetect(t *testing.T) {
+	types := mojo.NewTypes()
+	types.Register("md", "text/markdown", "text/x-markdown")
+	types.Alias("json", "text/json")
+
+	tests := []struct {
+		accept string
+		expect []string
+	}{
+		{"application/json", []string{"json"}},
+		{"application/json; charset=utf-8", []string{"json"}},
+		{"TEXT/HTML", []string{"htm", "html"}},
+		{"text/json", []string{"json"}},
+		{"text/x-markdown", []string{"md"}},
+		{"application/xml, text/xml", []string{"xml"}},
+		{"image/png;q=0.5, application/json", []string{"json", "png"}},
+		{"application/json;q=0, image/png", []string{"png"}},
+		{"image/*, image/png", []string{"png", "gif", "ico", "jpeg", "jpg", "svg"}},
+		{"*/*", []string{}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := types.Detect(tt.accept); !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("Detect(%q): %v; Expect: %v", tt.accept, got, tt.expect)
+		}
+	}
+}